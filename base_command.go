@@ -3,13 +3,12 @@ package main
 import (
 	"errors"
 	"flag"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
+	"strconv"
 	"text/template"
 
+	"github.com/three-plus-three/gengen/generator"
 	"github.com/three-plus-three/gengen/types"
 )
 
@@ -20,7 +19,17 @@ type baseCommand struct {
 	output   string
 	theme    string
 	override bool
+	verify   bool
 	funcs    template.FuncMap
+
+	flags     *flag.FlagSet
+	cmdline   map[string]bool
+	config    *projectConfig
+	variables map[string]interface{}
+	paths     map[string]string
+
+	// staging 不为空时, 生成的文件都放在它里面, 由调用者 (如 mvc 命令) 统一提交
+	staging *generator.StagingSink
 }
 
 func (cmd *baseCommand) CopyFrom(b *baseCommand) {
@@ -29,258 +38,163 @@ func (cmd *baseCommand) CopyFrom(b *baseCommand) {
 	cmd.output = b.output
 	cmd.theme = b.theme
 	cmd.override = b.override
+	cmd.verify = b.verify
+	cmd.config = b.config
+	cmd.variables = b.variables
+	cmd.paths = b.paths
+	cmd.staging = b.staging
 }
 
 // Flags - 申明参数
 func (cmd *baseCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.flags = fs
 	fs.StringVar(&cmd.ns, "namespace", "models", "the namespace name")
 	fs.StringVar(&cmd.root, "root", "", "the input target")
 	fs.StringVar(&cmd.output, "output", "", "the output target")
 	fs.StringVar(&cmd.theme, "theme", "", "the theme target")
 	fs.BoolVar(&cmd.override, "override", false, "")
+	fs.BoolVar(&cmd.verify, "verify", false, "type-check the generated go code before writing it")
 	return fs
 }
 
-func (cmd *baseCommand) loadTables() ([]*types.ClassSpec, error) {
-	files, err := filepath.Glob(filepath.Join(cmd.root, "*"))
+// loadConfig 读取项目配置文件 gengen.yaml, 用其中的值代替没有在命令行中指定的参数
+func (cmd *baseCommand) loadConfig(name string) error {
+	cmd.cmdline = cmdlineFlags(cmd.flags)
+	cfg, err := loadConfig(cmd.flags, name)
 	if err != nil {
-		return nil, errors.New("search root directory fail, " + err.Error())
+		return err
 	}
-
-	return types.LoadYAMLFiles(files)
+	cmd.config = cfg
+	cmd.variables = cfg.variables(name)
+	cmd.paths = cfg.paths(name)
+	return nil
 }
 
-func (cmd *baseCommand) loadFile(nm string) ([]byte, error) {
-	if cmd.theme != "" {
-		file := filepath.Join(cmd.root, cmd.theme, nm+".tpl.go")
-		bs, e := ioutil.ReadFile(file)
-		if e == nil {
-			return bs, nil
-		}
-		if !os.IsNotExist(e) {
-			return nil, errors.New("load template fail, " + e.Error())
-		}
+// isFlagSet 判断参数是否在命令行中显式指定了, 从配置文件中读取的值不算
+func (cmd *baseCommand) isFlagSet(name string) bool {
+	if cmd.cmdline == nil {
+		return isFlagSet(cmd.flags, name)
 	}
+	return cmd.cmdline[name]
+}
 
-	file := filepath.Join(cmd.root, "default", nm+".tpl.go")
-	bs, e := ioutil.ReadFile(file)
-	if e == nil {
-		return bs, nil
-	}
-	if !os.IsNotExist(e) {
-		return nil, errors.New("load template fail, " + e.Error())
+// options 返回生成器的选项
+func (cmd *baseCommand) options() generator.Options {
+	return generator.Options{
+		Namespace: cmd.ns,
+		Root:      cmd.root,
+		Theme:     cmd.theme,
+		Override:  cmd.override,
+		Variables: cmd.variables,
+		Funcs:     cmd.funcs,
+		Paths:     cmd.paths,
 	}
+}
 
-	return textDefault(nm), nil
+func (cmd *baseCommand) loadTables() ([]*types.ClassSpec, error) {
+	return generator.LoadSpecs(cmd.root)
 }
 
-func (cmd *baseCommand) newTemplate(name string, funcs template.FuncMap) (*template.Template, error) {
-	locals := template.FuncMap{
-		"set": func(ctx map[string]interface{}, name string, value interface{}) string {
-			ctx[name] = value
-			return ""
-		},
-		"goify":             Goify,
-		"gotype":            GoTypename,
-		"underscore":        types.Underscore,
-		"tableize":          types.Tableize,
-		"singularize":       types.Singularize,
-		"pluralize":         types.Pluralize,
-		"camelizeDownFirst": types.CamelizeDownFirst,
-		"toFormat":          toFormatFunc,
-		"omitempty": func(t *types.FieldSpec) bool {
-			return !t.IsRequired
-		},
-		"editDisabled": func(f interface{}) bool {
-			return HasFeature(f, "editDisabled")
-		},
-		"newDisabled": func(f interface{}) bool {
-			return HasFeature(f, "newDisabled")
-		},
-		"deleteDisabled": func(f interface{}) bool {
-			return HasFeature(f, "deleteDisabled")
-		},
-		"valueInAnnotations": ValueInAnnotations,
-		"hasFeature":         HasFeature,
-		"hasAnyFeatures": func(f interface{}, names ...string) bool {
-			for _, nm := range names {
-				if HasFeature(f, nm) {
-					return true
-				}
-			}
-			return false
-		},
-		"hasAllFeatures": func(f interface{}, names ...string) bool {
-			for _, nm := range names {
-				if !HasFeature(f, nm) {
-					return false
-				}
-			}
-			return true
-		},
-		"fieldExists": func(cls *types.ClassSpec, fieldName string) bool {
-			for _, field := range cls.Fields {
-				if field.Name == fieldName {
-					return true
-				}
-			}
-			return false
-		},
-		"field": func(cls *types.ClassSpec, fieldName string) types.FieldSpec {
-			for _, field := range cls.Fields {
-				if field.Name == fieldName {
-					return field
-				}
-			}
-			log.Fatalln(errors.New("field '" + fieldName + "' isn't exists in the " + cls.Name))
-			os.Exit(-1)
-			return types.FieldSpec{}
-		},
-		"hasEnumerations": func(f types.FieldSpec) bool {
-			if f.Restrictions == nil {
-				return false
-			}
-			return len(f.Restrictions.Enumerations) > 0
-		},
-		"isBelongsTo": func(cls *types.ClassSpec, f types.FieldSpec) bool {
-			for _, belongsTo := range cls.BelongsTo {
-				if belongsTo.Name == f.Name {
-					return true
-				}
-			}
-			return false
-		},
-		"belongsTo": func(cls *types.ClassSpec, f types.FieldSpec) *types.BelongsTo {
-			for _, belongsTo := range cls.BelongsTo {
-				if belongsTo.Name == f.Name {
-					return &belongsTo
-				}
-			}
+// checkOutput 检查输出目录, 目录不存在时会在提交时创建
+func (cmd *baseCommand) checkOutput() error {
+	if cmd.output == "" {
+		return nil
+	}
+	st, err := os.Stat(cmd.output)
+	if err != nil {
+		if os.IsNotExist(err) {
 			return nil
-		}}
-
-	if len(cmd.funcs) != 0 {
-		for k, v := range cmd.funcs {
-			funcs[k] = v
 		}
+		return err
 	}
+	if !st.IsDir() {
+		return errors.New(("'" + cmd.output + "' isn't directory."))
+	}
+	return nil
+}
 
-	bs, e := cmd.loadFile(name)
-	if e != nil {
-		return nil, e
+// newSink 返回生成器的输出目标, 文件先放在暂存区中, 全部生成成功后调用 commit
+// 才写到 output 目录, 这样生成失败时不会留下不完整的文件
+func (cmd *baseCommand) newSink() (generator.Sink, func() error) {
+	if cmd.staging != nil {
+		return generator.WithDir(cmd.staging, cmd.output), func() error { return nil }
+	}
+	staging := generator.NewStagingSink(generator.DirSink{})
+	return generator.WithDir(staging, cmd.output), func() error {
+		return cmd.commit(staging)
 	}
+}
 
-	return template.New("").Delims("[[", "]]").Funcs(funcs).Funcs(locals).Parse(string(bs))
+// commit 将暂存区中的文件写到磁盘上, 指定了 verify 参数时先检查生成的 go 代码,
+// 有错误时不写入任何文件
+func (cmd *baseCommand) commit(staging *generator.StagingSink) error {
+	if cmd.verify {
+		errs, err := generator.Verify(staging, true)
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			for _, e := range errs {
+				log.Println("[VERIFY]", e)
+			}
+			return errors.New("verify fail, " + strconv.Itoa(len(errs)) + " errors are found in the generated code")
+		}
+	}
+	return staging.Commit()
 }
 
 // Run - 生成数据库模型代码
-func (cmd *baseCommand) runAll(args []string, cb func(tables []*types.ClassSpec) error) error {
+func (cmd *baseCommand) runAll(args []string, name string, opts generator.Options) error {
 	// if e := cmd.init(); e != nil {
 	//  return e
 	// }
 
-	if cmd.output != "" {
-		if st, err := os.Stat(cmd.output); err != nil {
-			if os.IsNotExist(err) {
-				return err
-			}
-
-			if err := os.MkdirAll(cmd.output, 0); err != nil {
-				return err
-			}
-		} else if !st.IsDir() {
-			return errors.New(("'" + cmd.output + "' isn't directory."))
-		}
+	if err := cmd.checkOutput(); err != nil {
+		return err
 	}
 
 	tables, e := cmd.loadTables()
 	if nil != e {
 		return e
 	}
-	return cb(tables)
+	sink, commit := cmd.newSink()
+	if err := generator.New(opts, tables, sink).Project(name, tables); err != nil {
+		return err
+	}
+	return commit()
 }
 
 // Run - 生成数据库模型代码
-func (cmd *baseCommand) run(args []string, cb func(table *types.ClassSpec) error) error {
+func (cmd *baseCommand) run(args []string, name string, opts generator.Options) error {
 	// if e := cmd.init(); e != nil {
 	//  return e
 	// }
 
-	if cmd.output != "" {
-		if st, err := os.Stat(cmd.output); err != nil {
-			if !os.IsNotExist(err) {
-				return err
-			}
-
-			if err := os.MkdirAll(cmd.output, 0); err != nil {
-				return err
-			}
-		} else if !st.IsDir() {
-			return errors.New(("'" + cmd.output + "' isn't directory."))
-		}
+	if err := cmd.checkOutput(); err != nil {
+		return err
 	}
 
 	tables, e := cmd.loadTables()
 	if nil != e {
 		return e
 	}
-	if cmd.funcs == nil {
-		cmd.funcs = template.FuncMap{}
-	}
-	cmd.funcs["class"] = func(name string) *types.ClassSpec {
-		for _, cs := range tables {
-			if cs.Name == name {
-				return cs
-			}
-		}
-		return nil
-	}
-	cmd.funcs["referenceFields"] = ReferenceFields
-	/*
-			cls
-			var refCls *types.ClassSpec
-			for _, cs := range tables {
-				if cs.Name == name {
-					refCls = cs
-					break
-				}
-			}
-			if refCls == nil {
-				panic(errors.New("referenceFields of '" + f.Name + "' isn't string array in the " + cls.Name))
-			}
-
-			var fields = make([]types.FieldSpec, 0, len(names))
-			for _, name := range names {
-				found := false
-				for _, field := range cls.Fields {
-					if field.Name == fieldName {
-						found = true
-						fields = append(fields, field)
-						break
-					}
-				}
-				if !found {
-					panic(errors.New("field '" + name + "' isn't exists in the " + cls.Name))
-				}
-			}
-			return fields
-		}
-	*/
+	sink, commit := cmd.newSink()
+	gen := generator.New(opts, tables, sink)
 
 	if len(args) > 0 {
-		for _, name := range args {
-			log.Println("[GEN] ", name)
+		for _, clsName := range args {
+			log.Println("[GEN] ", clsName)
 			var table *types.ClassSpec
 			for _, cs := range tables {
-				if cs.Name == name {
+				if cs.Name == clsName {
 					table = cs
 					break
 				}
 			}
 
 			if table == nil {
-				log.Println("[FAIL]", name)
-				return errors.New("'" + name + "' isn't found")
+				log.Println("[FAIL]", clsName)
+				return errors.New("'" + clsName + "' isn't found")
 			}
 
 			// var out io.Writer = os.Stdout
@@ -301,7 +215,7 @@ func (cmd *baseCommand) run(args []string, cb func(table *types.ClassSpec) error
 			//  out = f
 			// }
 
-			if e := cb(table); nil != e {
+			if e := gen.Generate(name, table); nil != e {
 				return e
 			}
 		}
@@ -315,132 +229,13 @@ func (cmd *baseCommand) run(args []string, cb func(table *types.ClassSpec) error
 			// }
 			// defer f.Close()
 
-			if e := cb(table); nil != e {
+			if e := gen.Generate(name, table); nil != e {
 				return e
 			}
 		}
 	}
-	return nil
-}
-
-func (cmd *baseCommand) executeTempate(override bool, names []string, funcs template.FuncMap, params interface{}, fname string) error {
-	var out *os.File
-	var err error
-
-	dirname := filepath.Dir(fname)
-	if dirname != "" {
-		if err = os.MkdirAll(dirname, 0666); err != nil {
-			if !os.IsExist(err) {
-				return err
-			}
-		}
-	}
-
-	if !override {
-		out, err = os.OpenFile(fname, os.O_CREATE|os.O_EXCL, 0666)
-	} else {
-		out, err = os.OpenFile(fname, os.O_CREATE|os.O_TRUNC, 0666)
-	}
-	if nil != err {
-		if os.IsExist(err) {
-			fmt.Println("[WARN] [EXISTS] skip", fname)
-			return nil
-		}
-		return err
-	}
-	defer out.Close()
-
-	for _, name := range names {
-		tpl, err := cmd.newTemplate(name, funcs)
-		if nil != err {
-			return errors.New("load '" + name + "' template," + err.Error())
-		}
-
-		if err := tpl.Execute(out, params); err != nil {
-			out.Close()
-			os.Remove(fname)
-			return errors.New("execute '" + name + "' template," + err.Error())
-		}
-	}
-	return nil
-}
-
-type ReferenceField struct {
-	Name  string
-	Label string
-}
-
-func ReferenceFields(f types.FieldSpec) []ReferenceField {
-	if f.Annotations == nil {
-		return nil
-	}
-
-	a := f.Annotations["referenceFields"]
-	if a == nil {
-		return nil
-	}
-	var names []ReferenceField
-	switch values := a.(type) {
-	case []string:
-		for _, s := range values {
-			names = append(names, ReferenceField{Name: s})
-		}
-	case []interface{}:
-		names = make([]ReferenceField, 0, len(values))
-		for _, v := range values {
-			switch value := v.(type) {
-			case string:
-				names = append(names, ReferenceField{Name: value})
-			case map[string]interface{}:
-				field := ReferenceField{Name: fmt.Sprint(value["name"])}
-				if label := value["label"]; label != nil {
-					field.Label = fmt.Sprint(label)
-				}
-				names = append(names, field)
-			case map[interface{}]interface{}:
-				field := ReferenceField{Name: fmt.Sprint(value["name"])}
-				for k, vv := range value {
-					switch fmt.Sprint(k) {
-					case "name":
-						field.Name = fmt.Sprint(vv)
-					case "label":
-						field.Label = fmt.Sprint(vv)
-					}
-				}
-				names = append(names, field)
-			default:
-				fmt.Printf("%T %v\r\n", v, v)
-				panic(errors.New("referenceFields of '" + f.Name + "' isn't string array or object array"))
-			}
-		}
-	default:
-		fmt.Printf("%T %v\r\n", a, a)
-		panic(errors.New("referenceFields of '" + f.Name + "' isn't string array or object array"))
-	}
-
-	return names
-}
-
-func toFormatFunc(f types.FieldSpec) string {
-	if f.Annotations != nil {
-		if format := f.Annotations["columnFormat"]; format != nil {
-			return fmt.Sprint(format)
-		}
-
-		if format := f.Annotations["enumerationSource"]; format != nil {
-			return fmt.Sprint(format) + "_format"
-		}
-	}
-
-	if f.Restrictions != nil && len(f.Restrictions.Enumerations) > 0 {
-		return f.Name + "_format"
-	}
-	switch f.Format {
-	case "net.IP", "ip", "mac", "email":
-		return ""
-	case "":
-		return ""
-	default:
-		return f.Format
+	if e := gen.Project(name, tables); nil != e {
+		return e
 	}
+	return commit()
 }
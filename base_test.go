@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+)
+
+func TestDialect(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if d := dialectOf(db); d != SQLite {
+		t.Error("dialect of sqlite is", d)
+	}
+	if d := dialectOf(WithDialect(db, MySQL)); d != MySQL {
+		t.Error("dialect of runner is", d)
+	}
+	model := &ViewModel{TableName: "tpt_books", Dialect: Postgres}
+	if d := model.DialectOf(db); d != Postgres {
+		t.Error("dialect of model is", d)
+	}
+
+	name := &ColumnModel{Name: "name"}
+	for _, test := range []struct {
+		dialect  Dialect
+		quoted   string
+		excepted string
+	}{
+		{Postgres, `"name"`, "SELECT * FROM tpt_books WHERE name ILIKE $1"},
+		{MySQL, "`name`", "SELECT * FROM tpt_books WHERE LOWER(name) LIKE LOWER(?)"},
+		{SQLite, `"name"`, "SELECT * FROM tpt_books WHERE LOWER(name) LIKE LOWER(?)"},
+	} {
+		if quoted := test.dialect.Quote("name"); quoted != test.quoted {
+			t.Errorf("%s: quoted is %s", test.dialect, quoted)
+		}
+
+		model := &ViewModel{TableName: "tpt_books", Dialect: test.dialect}
+		sqlStr, args, err := model.Where(name.ILIKE("a%")).Select("*").From(model.TableName).
+			PlaceholderFormat(test.dialect.PlaceholderFormat()).ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlStr != test.excepted || len(args) != 1 {
+			t.Errorf("%s: excepted %s, actual %s", test.dialect, test.excepted, sqlStr)
+		}
+	}
+
+	if _, err := db.Exec("CREATE TABLE tpt_books (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	runner := WithDialect(db, SQLite)
+	if _, err := squirrel.Insert("tpt_books").Columns("name").Values("Go").RunWith(runner).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := squirrel.Select("count(*)").From("tpt_books").RunWith(runner).QueryRow().Scan(&count); err != nil || count != 1 {
+		t.Error("count is", count, err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE tpt_books (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	runner := WithDialect(db, SQLite)
+	model := &ViewModel{TableName: "tpt_books"}
+	if _, err := model.Count(runner); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := model.CountContext(ctx, runner); err == nil {
+		t.Error("excepted error with canceled context")
+	}
+}
+
+func TestInsertManyAndUpsert(t *testing.T) {
+	for _, test := range []struct {
+		dialect  Dialect
+		excepted string
+	}{
+		{Postgres, `ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`},
+		{MySQL, "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)"},
+		{SQLite, `ON CONFLICT ("id") DO NOTHING`},
+	} {
+		var columns []string
+		if test.dialect != SQLite {
+			columns = []string{"name"}
+		}
+		if suffix := test.dialect.Upsert([]string{"id"}, columns); suffix != test.excepted {
+			t.Errorf("%s: excepted %s, actual %s", test.dialect, test.excepted, suffix)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE tpt_books (id INTEGER PRIMARY KEY, isbn TEXT UNIQUE, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	old := InsertChunkSize
+	InsertChunkSize = 2
+	defer func() { InsertChunkSize = old }()
+
+	runner := WithDialect(db, SQLite)
+	model := &DbModel{ViewModel: ViewModel{TableName: "tpt_books"},
+		KeyNames:   []string{"id"},
+		UniqueKeys: map[string][]string{"tpt_books_isbn_key": {"isbn"}}}
+	count, err := model.InsertMany(runner, []string{"isbn", "name"}, [][]interface{}{
+		{"1", "a"}, {"2", "b"}, {"3", "c"}, {"4", "d"}, {"5", "e"}})
+	if err != nil || count != 5 {
+		t.Fatal("insert", count, err)
+	}
+	if _, err := model.InsertMany(runner, []string{"isbn", "name"}, [][]interface{}{{"6"}}); err == nil {
+		t.Error("excepted error with invalid row")
+	}
+
+	if _, err := model.Upsert(runner, "tpt_books_isbn_key", []string{"isbn", "name"}, [][]interface{}{
+		{"1", "aa"}, {"6", "f"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Upsert(runner, "", []string{"id", "isbn", "name"}, [][]interface{}{{2, "2", "bb"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Upsert(runner, "not_exists", []string{"isbn"}, [][]interface{}{{"7"}}); err == nil {
+		t.Error("excepted error with unknown key")
+	}
+
+	var names string
+	if err := db.QueryRow("SELECT group_concat(name, ',') FROM (SELECT name FROM tpt_books ORDER BY id)").Scan(&names); err != nil {
+		t.Fatal(err)
+	}
+	if names != "aa,bb,c,d,e,f" {
+		t.Error("names is", names)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	model := &DbModel{ViewModel: ViewModel{TableName: "tpt_books"}, KeyNames: []string{"id"}}
+	id, name := ColumnModel{Name: "id"}, ColumnModel{Name: "name"}
+
+	for _, test := range []struct {
+		query    *Query
+		excepted string
+		args     int
+	}{
+		{model.Query(name.LIKE("a%")).OrderBy(name, DESC).Limit(10).Offset(20),
+			"SELECT * FROM tpt_books WHERE name LIKE $1 ORDER BY name DESC LIMIT 10 OFFSET 20", 1},
+		{model.Query().OrderBy(id, "").After(3).Limit(10),
+			"SELECT * FROM tpt_books WHERE ((id > $1)) ORDER BY id ASC LIMIT 10", 1},
+		{model.Query().OrderBy(name, DESC).OrderBy(id, ASC).After("a", 3),
+			"SELECT * FROM tpt_books WHERE ((name < $1) OR (name = $2 AND id > $3)) ORDER BY name DESC, id ASC", 3},
+		{model.Query().OrderBy(id, ASC).Paginate(3, 10),
+			"SELECT * FROM tpt_books ORDER BY id ASC LIMIT 10 OFFSET 20", 0},
+	} {
+		builder, err := test.query.ToSelect(Postgres)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlStr, args, err := builder.Columns("*").From(model.TableName).PlaceholderFormat(squirrel.Dollar).ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlStr != test.excepted || len(args) != test.args {
+			t.Errorf("excepted %s, actual %s %v", test.excepted, sqlStr, args)
+		}
+	}
+
+	if _, err := model.Query().OrderBy(name, ASC).After("a").ToSelect(Postgres); err == nil {
+		t.Error("excepted error when order by a column that isn't unique")
+	}
+	if _, err := model.Query().OrderBy(id, ASC).After(1, 2).ToSelect(Postgres); err == nil {
+		t.Error("excepted error when count of values is mismatch")
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE tpt_books (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	runner := WithDialect(db, SQLite)
+	if _, err := model.InsertMany(runner, []string{"name"}, [][]interface{}{{"a"}, {"b"}, {"c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	query := model.Query(name.NEQ("b")).OrderBy(id, DESC)
+	count, err := query.Paginate(2, 1).Count(runner)
+	if err != nil || count != 2 {
+		t.Error("count is", count, err)
+	}
+	builder, err := query.Paginate(2, 1).ToSelect(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	if err := builder.Columns("name").From(model.TableName).RunWith(runner).QueryRow().Scan(&value); err != nil || value != "a" {
+		t.Error("value is", value, err)
+	}
+}
+
+func TestExpr(t *testing.T) {
+	id, name, tags := &ColumnModel{Name: "id"}, &ColumnModel{Name: "name"}, &ColumnModel{Name: "tags"}
+	expr := Or(And(id.IN(1, 2), name.NotIN([]string{"a", "b'; DROP TABLE tpt_books; --"})),
+		Not(id.BETWEEN(10, 20)),
+		name.IsDistinctFrom("c"),
+		name.ILIKE("d%"),
+		tags.IN([]byte("e")))
+	args := []interface{}{1, 2, "a", "b'; DROP TABLE tpt_books; --", 10, 20, "c", "d%", []byte("e")}
+
+	for _, test := range []struct {
+		dialect  Dialect
+		excepted string
+	}{
+		{Postgres, "SELECT * FROM tpt_books WHERE ((id IN ($1,$2) AND name NOT IN ($3,$4)) OR NOT (id BETWEEN $5 AND $6) OR " +
+			"name IS DISTINCT FROM $7 OR name ILIKE $8 OR tags IN ($9))"},
+		{MySQL, "SELECT * FROM tpt_books WHERE ((id IN (?,?) AND name NOT IN (?,?)) OR NOT (id BETWEEN ? AND ?) OR " +
+			"NOT (name <=> ?) OR LOWER(name) LIKE LOWER(?) OR tags IN (?))"},
+		{SQLite, "SELECT * FROM tpt_books WHERE ((id IN (?,?) AND name NOT IN (?,?)) OR NOT (id BETWEEN ? AND ?) OR " +
+			"name IS NOT ? OR LOWER(name) LIKE LOWER(?) OR tags IN (?))"},
+	} {
+		model := &ViewModel{TableName: "tpt_books", Dialect: test.dialect}
+		sqlStr, sqlArgs, err := model.Where(expr).Select("*").From(model.TableName).
+			PlaceholderFormat(test.dialect.PlaceholderFormat()).ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlStr != test.excepted {
+			t.Errorf("%s: excepted %s, actual %s", test.dialect, test.excepted, sqlStr)
+		}
+		if fmt.Sprint(sqlArgs) != fmt.Sprint(args) {
+			t.Errorf("%s: excepted %v, actual %v", test.dialect, args, sqlArgs)
+		}
+	}
+
+	if sqlStr, _, _ := And().ToSql(); sqlStr != "(1=1)" {
+		t.Error("empty and is", sqlStr)
+	}
+	if sqlStr, _, _ := Or().ToSql(); sqlStr != "(1=0)" {
+		t.Error("empty or is", sqlStr)
+	}
+	for _, empty := range []Expr{id.IN(), id.IN([]int64{}), id.NotIN(), id.NotIN([]string{})} {
+		if _, _, err := empty.ToSql(); err == nil {
+			t.Error("excepted error with empty values of", empty.Operator)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFilename - 项目配置文件的文件名
+const ConfigFilename = "gengen.yaml"
+
+// generatorConfig - 某个生成器的配置，除 variables 和 paths 之外的键均为命令行参数名
+type generatorConfig struct {
+	Variables map[string]interface{} `yaml:"variables,omitempty"`
+	Paths     map[string]string      `yaml:"paths,omitempty"`
+	Values    map[string]interface{} `yaml:",inline"`
+}
+
+// projectConfig - 项目配置文件 gengen.yaml, 例如
+//
+//	namespace: models
+//	root: specs
+//	theme: bootstrap
+//	variables:
+//	  company: ACME
+//	paths:
+//	  struct: "{{.Namespace}}/{{.UnderscorePlural}}.go"
+//	generators:
+//	  controller:
+//	    controller: BaseController
+//	    projectPath: example.com/app
+//
+// 顶层和 generators 下的键均为对应命令的参数名，命令行中显式指定的参数优先。
+type projectConfig struct {
+	filename   string
+	Variables  map[string]interface{}     `yaml:"variables,omitempty"`
+	Paths      map[string]string          `yaml:"paths,omitempty"`
+	Generators map[string]generatorConfig `yaml:"generators,omitempty"`
+	Values     map[string]interface{}     `yaml:",inline"`
+}
+
+// pathFlags - 值为文件或目录的参数, 配置文件中它们的相对路径是相对于配置文件所在的目录,
+// 值为逗号分隔的列表时每一项分别处理. projectPath (import 路径), layouts (模板名的前缀),
+// customPath (URL 的前缀) 和 theme (root 下的目录名) 不是文件路径, 不做处理
+var pathFlags = map[string]bool{
+	"root":     true,
+	"output":   true,
+	"file":     true,
+	"ddl":      true,
+	"snapshot": true,
+	"specs":    true,
+	"golden":   true,
+}
+
+// loadConfig 读取项目配置文件 gengen.yaml, 并将生成器 name 的配置应用到参数 fs 上,
+// 没有配置文件时返回 nil
+func loadConfig(fs *flag.FlagSet, name string) (*projectConfig, error) {
+	cfg, err := loadProjectConfig()
+	if err != nil || cfg == nil || fs == nil {
+		return cfg, err
+	}
+	cmdline := cmdlineFlags(fs)
+	return cfg, cfg.apply(fs, name, cfg.values(name), cfg.isShared(name), func(flagName string) bool {
+		return cmdline[flagName]
+	})
+}
+
+// loadProjectConfig 从当前目录开始向上查找 gengen.yaml, 没有找到时返回 nil
+func loadProjectConfig() (*projectConfig, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return findProjectConfig(dir)
+}
+
+// findProjectConfig 从 dir 开始向上查找 gengen.yaml, 没有找到时返回 nil
+func findProjectConfig(dir string) (*projectConfig, error) {
+	for {
+		filename := filepath.Join(dir, ConfigFilename)
+		if st, e := os.Stat(filename); nil == e && !st.IsDir() {
+			return readProjectConfig(filename)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func readProjectConfig(filename string) (*projectConfig, error) {
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New("load '" + filename + "' fail, " + err.Error())
+	}
+
+	cfg := &projectConfig{filename: filename}
+	if err := yaml.Unmarshal(bs, cfg); err != nil {
+		return nil, errors.New("load '" + filename + "' fail, " + err.Error())
+	}
+
+	dir := filepath.Dir(filename)
+	resolvePaths(dir, cfg.Values)
+	for _, gen := range cfg.Generators {
+		resolvePaths(dir, gen.Values)
+	}
+	return cfg, nil
+}
+
+// resolvePaths 将 pathFlags 中的参数的相对路径转换为相对于 dir 的路径
+func resolvePaths(dir string, values map[string]interface{}) {
+	for key, value := range values {
+		s, ok := value.(string)
+		if !ok || s == "" || !pathFlags[strings.Replace(key, "-", "_", -1)] {
+			continue
+		}
+		paths := strings.Split(s, ",")
+		for idx, p := range paths {
+			p = strings.TrimSpace(p)
+			switch strings.ToLower(p) {
+			case "", "stdout", "stderr":
+			default:
+				if !filepath.IsAbs(p) {
+					p = filepath.Join(dir, p)
+				}
+			}
+			paths[idx] = p
+		}
+		values[key] = strings.Join(paths, ",")
+	}
+}
+
+// values 返回指定生成器的参数值，生成器中的值覆盖顶层的值
+func (cfg *projectConfig) values(name string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for k, v := range cfg.Values {
+		values[k] = v
+	}
+	if gen, ok := cfg.Generators[name]; ok {
+		for k, v := range gen.Values {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// variables 返回指定生成器的自定义模板变量，生成器中的值覆盖顶层的值
+func (cfg *projectConfig) variables(name string) map[string]interface{} {
+	variables := map[string]interface{}{}
+	if cfg == nil {
+		return variables
+	}
+	for k, v := range cfg.Variables {
+		variables[k] = v
+	}
+	if gen, ok := cfg.Generators[name]; ok {
+		for k, v := range gen.Variables {
+			variables[k] = v
+		}
+	}
+	return variables
+}
+
+// paths 返回指定生成器的输出路径模板，生成器中的值覆盖顶层的值
+func (cfg *projectConfig) paths(name string) map[string]string {
+	paths := map[string]string{}
+	if cfg == nil {
+		return paths
+	}
+	for k, v := range cfg.Paths {
+		paths[k] = v
+	}
+	if gen, ok := cfg.Generators[name]; ok {
+		for k, v := range gen.Paths {
+			paths[k] = v
+		}
+	}
+	return paths
+}
+
+// isShared 返回判断键是否为顶层的共用配置的函数, 生成器 name 中的配置不是共用的
+func (cfg *projectConfig) isShared(name string) func(key string) bool {
+	return func(key string) bool {
+		if gen, ok := cfg.Generators[name]; ok {
+			if _, ok := gen.Values[key]; ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// apply 将配置中的值设置到生成器 command 的参数上，已在命令行中显式指定的参数 (isSet 返回 true)
+// 不会被修改. 不是参数的键: 是顶层的共用配置 (isShared 返回 true) 时它可能只用于其它的
+// 命令, 只记录一个警告, 否则是拼写错误, 返回错误
+func (cfg *projectConfig) apply(fs *flag.FlagSet, command string, values map[string]interface{}, isShared, isSet func(name string) bool) error {
+	for key, value := range values {
+		name := key
+		if fs.Lookup(name) == nil {
+			name = strings.Replace(key, "-", "_", -1)
+			if fs.Lookup(name) == nil {
+				if isShared != nil && isShared(key) {
+					log.Println("[WARN] '" + key + "' in '" + cfg.filename + "' isn't a flag of '" + command + "', ignored")
+					continue
+				}
+				return errors.New("load '" + cfg.filename + "' fail, '" + key + "' isn't a flag of '" + command + "'")
+			}
+		}
+		if isSet != nil && isSet(name) {
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+			return errors.New("load '" + cfg.filename + "' fail, value of '" + name + "' is invalid, " + err.Error())
+		}
+	}
+	return nil
+}
+
+// cmdlineFlags 返回在命令行中显式指定了的参数, 它必须在应用配置之前调用, 因为
+// apply 用 fs.Set 设置的参数也会被 fs.Visit 访问到
+func cmdlineFlags(fs *flag.FlagSet) map[string]bool {
+	flags := map[string]bool{}
+	if fs != nil {
+		fs.Visit(func(f *flag.Flag) {
+			flags[f.Name] = true
+		})
+	}
+	return flags
+}
+
+// isFlagSet 判断参数是否被设置了, 包括在命令行中指定的和从配置文件中读取的值
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	if fs == nil {
+		return false
+	}
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
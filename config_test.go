@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+namespace: models
+root: specs
+db-prefix: tpt_
+theme: bootstrap
+variables:
+  company: ACME
+generators:
+  models:
+    file: models/models.go
+    ddl: "schema/a.sql, /abs/b.sql"
+    variables:
+      company: Example
+`
+
+func testConfigFlags() (*flag.FlagSet, map[string]*string) {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	values := map[string]*string{}
+	for _, name := range []string{"namespace", "root", "db_prefix", "file", "ddl"} {
+		values[name] = fs.String(name, "", "")
+	}
+	return fs, values
+}
+
+// withConfig 在临时目录中写入配置文件, 并切换到它的子目录 a/b 中, 返回临时目录和
+// 恢复工作目录并删除临时目录的函数
+func withConfig(t *testing.T, config string) (string, func()) {
+	dir, err := ioutil.TempDir("", "gengen_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, ConfigFilename), []byte(config), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	nested := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	if err := os.Chdir(nested); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return dir, cleanup
+}
+
+func TestConfig(t *testing.T) {
+	dir, cleanup := withConfig(t, testConfig)
+	defer cleanup()
+
+	// 从子目录向上查找配置文件, 命令行中的参数优先
+	fs, values := testConfigFlags()
+	if err := fs.Parse([]string{"-namespace", "cmd"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(fs, "models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		t.Fatal("config not found")
+	}
+	if cfg.filename != filepath.Join(dir, ConfigFilename) {
+		t.Error("filename: excepted", filepath.Join(dir, ConfigFilename), "got", cfg.filename)
+	}
+
+	for name, excepted := range map[string]string{
+		"namespace": "cmd",
+		"db_prefix": "tpt_",
+		"root":      filepath.Join(dir, "specs"),
+		"file":      filepath.Join(dir, "models", "models.go"),
+		"ddl":       filepath.Join(dir, "schema", "a.sql") + ",/abs/b.sql",
+	} {
+		if *values[name] != excepted {
+			t.Error(name+": excepted", excepted, "got", *values[name])
+		}
+	}
+
+	if company := cfg.variables("models")["company"]; company != "Example" {
+		t.Error("variables: excepted Example got", company)
+	}
+}
+
+func TestConfigGeneratorPrecedence(t *testing.T) {
+	_, cleanup := withConfig(t, `
+theme: shared
+namespace: shared
+generators:
+  views:
+    theme: special
+    namespace: special
+`)
+	defer cleanup()
+
+	// 生成器中的配置优先于顶层的配置, 命令行中的参数优先于它们
+	var cmd GenerateMVCCommand
+	fs := cmd.Flags(flag.NewFlagSet("mvc", flag.ContinueOnError))
+	if err := fs.Parse([]string{"-namespace", "cmd"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.loadConfig("mvc"); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.theme != "shared" || cmd.ns != "cmd" {
+		t.Error("mvc: excepted shared and cmd, got", cmd.theme, cmd.ns)
+	}
+
+	var views GenerateViewCommand
+	viewsFlags := views.Flags(flag.NewFlagSet("views", flag.ContinueOnError))
+	views.CopyFrom(&cmd.baseCommand)
+	if err := cmd.configure(&views.baseCommand, "views", viewsFlags); err != nil {
+		t.Fatal(err)
+	}
+	if views.theme != "special" || views.ns != "cmd" {
+		t.Error("views: excepted special and cmd, got", views.theme, views.ns)
+	}
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	cfg := &projectConfig{
+		filename: ConfigFilename,
+		Values:   map[string]interface{}{"theme": "bootstrap"},
+		Generators: map[string]generatorConfig{
+			"models": {Values: map[string]interface{}{"nullabel": "sql"}},
+		},
+	}
+
+	// 顶层的键可能是其它命令的参数, 只记录警告
+	fs, _ := testConfigFlags()
+	if err := cfg.apply(fs, "models", cfg.Values, cfg.isShared("models"), func(string) bool { return false }); err != nil {
+		t.Error(err)
+	}
+
+	// 生成器中的键不是参数时返回错误
+	fs, _ = testConfigFlags()
+	err := cfg.apply(fs, "models", cfg.values("models"), cfg.isShared("models"), func(string) bool { return false })
+	if err == nil {
+		t.Error("excepted error for 'nullabel'")
+	} else if !strings.Contains(err.Error(), "'nullabel' isn't a flag of 'models'") {
+		t.Error(err)
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	values := map[string]interface{}{
+		"output":      "out",
+		"specs":       "/abs/specs",
+		"file":        "stdout",
+		"projectPath": "example.com/app",
+		"layouts":     "layouts/",
+	}
+	resolvePaths("/project", values)
+
+	for name, excepted := range map[string]string{
+		"output":      filepath.Join("/project", "out"),
+		"specs":       "/abs/specs",
+		"file":        "stdout",
+		"projectPath": "example.com/app",
+		"layouts":     "layouts/",
+	} {
+		if values[name] != excepted {
+			t.Error(name+": excepted", excepted, "got", values[name])
+		}
+	}
+}
@@ -1,12 +1,6 @@
 package main
 
-import (
-	"flag"
-	"path/filepath"
-	"text/template"
-
-	"github.com/three-plus-three/gengen/types"
-)
+import "flag"
 
 // GenerateControllerCommand - 生成控制器
 type GenerateControllerCommand struct {
@@ -24,29 +18,15 @@ func (cmd *GenerateControllerCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
 
 // Run - 生成代码
 func (cmd *GenerateControllerCommand) Run(args []string) error {
-	return cmd.run(args, func(cls *types.ClassSpec) error {
-		funcs := template.FuncMap{"displayForBelongsTo": func(f types.FieldSpec) string {
-			ann := f.Annotations["display"]
-			if s, ok := ann.(string); ok {
-				return Goify(s, true)
-			}
-
-			fields := ReferenceFields(f)
-			if len(fields) >= 1 {
-				return Goify(fields[0].Name, true)
-			}
-
-			return "Name"
-		}}
-
-		params := map[string]interface{}{"namespace": cmd.ns,
-			"baseController": cmd.controller,
-			"projectPath":    cmd.projectPath,
-			"controllerName": Pluralize(cls.Name),
-			"modelName":      Pluralize(cls.Name),
-			"class":          cls}
+	if err := cmd.loadConfig("controller"); err != nil {
+		return err
+	}
+	return cmd.generate(args)
+}
 
-		return cmd.executeTempate(cmd.override, []string{"ns", "controller"}, funcs, params,
-			filepath.Join(cmd.output, Underscore(Pluralize(cls.Name))+".go"))
-	})
+func (cmd *GenerateControllerCommand) generate(args []string) error {
+	opts := cmd.options()
+	opts.BaseController = cmd.controller
+	opts.ProjectPath = cmd.projectPath
+	return cmd.run(args, "controller", opts)
 }
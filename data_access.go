@@ -1,15 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
 	"errors"
 	"flag"
-	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
-
-	_ "github.com/lib/pq"
 )
 
 // select constraint_column_usage.table_catalog as ftable_catalog,
@@ -36,12 +36,34 @@ type Table struct {
 	Schema        string
 	TableName     string
 	ClassName     string
+	Comment       string
 	IsView        bool
 	Columns       []Column
 	IsCombinedKey bool
 	PrimaryKey    []Column
 	HasCreatedAt  bool
 	HasUpdatedAt  bool
+	Indexes       []Index
+	BelongsTo     []Relation // 本表的外键引用的表
+	HasMany       []Relation // 引用本表的外键
+}
+
+// Relation - 一个单列的外键, Column 在 Table 中, 引用 Target 中的 TargetColumn
+type Relation struct {
+	Name         string // BelongsTo 中为外键名去掉 _id, HasMany 中为 Table 的复数形式
+	Table        string // 外键所在的表
+	ClassName    string
+	Column       Column
+	Target       string // 被引用的表
+	TargetClass  string
+	TargetColumn Column
+}
+
+// Index - 表上的索引
+type Index struct {
+	Name     string
+	Columns  []string
+	IsUnique bool
 }
 
 // Column entity in table `information_schema.columns`
@@ -51,10 +73,27 @@ type Column struct {
 	DbType string
 	GoType string
 
+	Comment string // 数据库中的注释, 如 PostgreSQL 的 COMMENT ON COLUMN
+
 	IsNullable   bool
 	IsPrimaryKey bool
 	IsForeignKey bool
 	IsSequence   bool
+
+	RefTable  string // 外键引用的表
+	RefColumn string // 外键引用的列, 为空时为被引用表的主键
+
+	Precision  int      // numeric 的精度, 为 0 表示没有限制
+	Scale      int      // numeric 的小数位数
+	EnumValues []string // DbType 为 enum 时的所有值
+	MaxLength  int      // varchar 和 char 的最大长度, 为 0 表示没有限制
+
+	DefaultValue string   // 缺省值, 只记录字符串, 数字和布尔值等常量
+	CheckValues  []string // CHECK (col IN (...)) 中允许的值
+	MinValue     string   // CHECK (col BETWEEN a AND b) 或 CHECK (col >= a) 中的最小值
+	MaxValue     string   // CHECK (col BETWEEN a AND b) 或 CHECK (col <= b) 中的最大值
+
+	NullStrategy string // 可以为 NULL 时字段的类型, 为空时为 zero, 见 models 命令的 -nullable 参数
 }
 
 type dbBase struct {
@@ -63,218 +102,318 @@ type dbBase struct {
 	dbCatalog string
 	dbSchema  string
 	dbPrefix  string
+	ddl       string
+	snapshot  string
+	include   string
+	exclude   string
 }
 
 func (cmd *dbBase) initFlags(fs *flag.FlagSet) *flag.FlagSet {
 	fs.StringVar(&cmd.dbURL, "db_url", "host=127.0.0.1 port=5432 dbname=test user=postgresql password=123456 sslmode=disable", "the db url")
-	fs.StringVar(&cmd.dbDrv, "db_drv", "postgres", "the db driver")
+	fs.StringVar(&cmd.dbDrv, "db_drv", "postgres", "the db driver, postgres, mysql or sqlite3")
 	fs.StringVar(&cmd.dbCatalog, "db_catalog", "test", "the db schema")
 	fs.StringVar(&cmd.dbSchema, "db_schema", "public", "the db schema")
-	fs.StringVar(&cmd.dbPrefix, "db_prefix", "test_", "the db prefix name")
+	fs.StringVar(&cmd.dbPrefix, "db_prefix", "test_", "the db prefix names separated by commas, they are removed from the class names")
+	fs.StringVar(&cmd.ddl, "ddl", "", "the .sql files or directories separated by commas, read tables from them instead of the database")
+	fs.StringVar(&cmd.snapshot, "snapshot", "", "the schema snapshot file written by the snapshot command, read tables from it instead of the database")
+	fs.StringVar(&cmd.include, "include", "", "the table name patterns separated by commas, only these tables are used, such as tpt_*,/^sys_(users|roles)$/")
+	fs.StringVar(&cmd.exclude, "exclude", "", "the table name patterns separated by commas, these tables are ignored")
 	return fs
 }
 
 // GetAll use to select all tables from `information_schema.tables`.
 func (cmd *dbBase) GetAllTables() ([]Table, error) {
-	db, e := sql.Open(cmd.dbDrv, cmd.dbURL)
+	tables, e := cmd.readTables()
 	if nil != e {
 		return nil, e
 	}
-	defer db.Close()
-
-	queryString := fmt.Sprintf(`SELECT
-            distinct t.table_name, t.table_schema, t.table_type
-        FROM
-            information_schema.tables t
-        LEFT JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
-             ON tc.table_catalog = t.table_catalog
-             AND tc.table_schema = t.table_schema
-             AND tc.table_name = t.table_name
-             AND tc.constraint_type = 'PRIMARY KEY'
-        LEFT JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
-             ON kcu.table_catalog = tc.table_catalog
-             AND kcu.table_schema = tc.table_schema
-             AND kcu.table_name = tc.table_name
-             AND kcu.constraint_name = tc.constraint_name
-        WHERE
-            t.table_catalog = '%s' AND
-            t.table_schema = '%s'`, cmd.dbCatalog, cmd.dbSchema)
-
-	rows, e := db.Query(queryString)
+	tables, e = cmd.filterTables(tables)
 	if nil != e {
 		return nil, e
 	}
-	defer rows.Close()
-
-	//fmt.Println(queryString)
-	var tables []Table
-	for rows.Next() {
-		var table Table
-		var tableType string
-		if e := rows.Scan(&table.TableName, &table.Schema, &tableType); nil != e {
+
+	for idx := range tables {
+		if e := cmd.initTable(&tables[idx], tables[idx].Columns); nil != e {
 			return nil, e
 		}
-		//fmt.Println(table.TableName)
+	}
+	initRelations(tables)
+	return tables, nil
+}
 
-		if "view" == strings.ToLower(tableType) {
-			table.IsView = true
-		}
+// readTables 从 -ddl 指定的 .sql 文件, -snapshot 指定的快照或数据库中读取表结构,
+// 返回的表还没有过滤, 也没有设置 ClassName, GoName 和 GoType 等
+func (cmd *dbBase) readTables() ([]Table, error) {
+	if cmd.ddl != "" {
+		return loadDDL(cmd.ddl)
+	}
+	if cmd.snapshot != "" {
+		return loadSnapshot(cmd.snapshot)
+	}
 
-		columns, e := cmd.getByTable(db, cmd.dbCatalog, cmd.dbSchema, table.TableName)
-		if nil != e {
-			return nil, errors.New("failed to read columns for " + table.TableName + " - " + e.Error())
-		}
+	dialect, e := cmd.dialect()
+	if nil != e {
+		return nil, e
+	}
 
-		table.Columns = columns
-		table.IsCombinedKey, table.PrimaryKey = getPrimaryKey(table.Columns)
-		table.ClassName = Typeify(strings.TrimPrefix(table.TableName, cmd.dbPrefix))
+	db, e := sql.Open(cmd.dbDrv, cmd.dbURL)
+	if nil != e {
+		return nil, e
+	}
+	defer db.Close()
 
-		//if "tpt_network_devices" == table.TableName {
-		//  fmt.Println(table.TableName, table.IsCombinedKey, table.PrimaryKey)
-		//}
-		for _, column := range columns {
-			if "created_at" == column.DbName {
-				table.HasCreatedAt = true
-			}
-			if "updated_at" == column.DbName {
-				table.HasUpdatedAt = true
-			}
-		}
+	return dialect.tables(db)
+}
 
-		tables = append(tables, table)
+// trimPrefix 删除表名的前缀, 有多个前缀时只删除第一个匹配的
+func (cmd *dbBase) trimPrefix(tableName string) string {
+	for _, prefix := range strings.Split(cmd.dbPrefix, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "" && strings.HasPrefix(tableName, prefix) {
+			return strings.TrimPrefix(tableName, prefix)
+		}
 	}
-	return tables, rows.Err()
+	return tableName
 }
 
-func (cmd *dbBase) isForeignKey(db *sql.DB, tableCatalog, tableSchema, tableName, columnName string) (bool, error) {
-	queryString := fmt.Sprintf(`SELECT count(*)
-    FROM
-        INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
-      LEFT JOIN
-        INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
-      ON
-        kcu.table_schema = tc.table_schema
-        AND kcu.table_name = tc.table_name
-        AND kcu.constraint_name = tc.constraint_name
-    WHERE 
-        tc.constraint_type = 'FOREIGN KEY'
-        AND kcu.table_catalog = '%s'
-        AND kcu.table_schema = '%s'
-        AND kcu.table_name = '%s' 
-        AND kcu.column_name = '%s'`, tableCatalog, tableSchema, tableName, columnName)
-
-	var count int
-	e := db.QueryRow(queryString).Scan(&count)
+// filterTables 按 -include 和 -exclude 过滤表, -include 为空时包含所有的表
+func (cmd *dbBase) filterTables(tables []Table) ([]Table, error) {
+	include, e := compileTablePatterns(cmd.include)
 	if nil != e {
-		return false, e
+		return nil, e
 	}
-	return count > 0, nil
-}
-
-// getByTable use to select columns from `information_schema.tables` of inputed tableName.
-func (cmd *dbBase) getByTable(db *sql.DB, tableCatalog, tableSchema, tableName string) ([]Column, error) {
-	queryString := fmt.Sprintf(`SELECT
-        distinct t.column_name,
-        t.is_nullable,
-        t.udt_name,
-        t.column_name = kcu.column_name as primary_key,
-        t.column_default IS NOT NULL AND t.column_default LIKE 'nextval%%' as is_sequence
-    FROM
-        INFORMATION_SCHEMA.COLUMNS t
-    LEFT JOIN
-        INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
-    ON
-        tc.table_schema = t.table_schema
-        AND tc.table_name = t.table_name
-        AND tc.constraint_type = 'PRIMARY KEY'
-    LEFT JOIN
-        INFORMATION_SCHEMA.KEY_COLUMN_USAGE kcu
-    ON
-        kcu.table_schema = tc.table_schema
-        AND kcu.table_name = tc.table_name
-        AND kcu.constraint_name = tc.constraint_name
-    WHERE t.table_catalog = '%s' and t.table_schema = '%s' and t.table_name = '%s'`, tableCatalog, tableSchema, tableName)
-	rows, e := db.Query(queryString)
+	exclude, e := compileTablePatterns(cmd.exclude)
 	if nil != e {
 		return nil, e
 	}
-	defer rows.Close()
-
-	var columns []Column
-	for rows.Next() {
-		var isNullable sql.NullString
-		var primaryKey sql.NullBool
-		var isSequence sql.NullBool
-
-		var column Column
-		if e := rows.Scan(&column.DbName,
-			&isNullable,
-			&column.DbType,
-			&primaryKey,
-			&isSequence); nil != e {
-			return nil, e
-		}
+	if len(include) == 0 && len(exclude) == 0 {
+		return tables, nil
+	}
 
-		if isNullable.Valid {
-			column.IsNullable = strings.ToLower(isNullable.String) == "yes"
+	filtered := make([]Table, 0, len(tables))
+	for _, table := range tables {
+		if len(include) > 0 && !matchTable(include, table.TableName) {
+			continue
 		}
-		if primaryKey.Valid {
-			column.IsPrimaryKey = primaryKey.Bool
+		if matchTable(exclude, table.TableName) {
+			continue
 		}
-		isSequenceByForeignKey := false
-		if isForeignKey, e := cmd.isForeignKey(db, tableCatalog, tableSchema, tableName, column.DbName); e == nil {
-			if "id" == column.DbName { // for tpt_managed_objects
-				column.IsPrimaryKey = true
-				isSequenceByForeignKey = true
-			} else {
-				column.IsForeignKey = isForeignKey
-			}
-			//if "id" == column.DbName {
-			//  fmt.Println(tableName, column.DbName, isForeignKey)
-			//}
+		filtered = append(filtered, table)
+	}
+	log.Println("[INFO]", len(filtered), "of", len(tables), "tables are selected")
+	return filtered, nil
+}
+
+// compileTablePatterns 编译用逗号分隔的表名模式, 用 / 括起来的是正则表达式, 其它的是
+// 支持 * 和 ? 的通配符
+func compileTablePatterns(s string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, pattern := range splitTablePatterns(s) {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
 		}
-		if isSequence.Valid {
-			column.IsSequence = isSequence.Bool
+
+		var expr string
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr = pattern[1 : len(pattern)-1]
+		} else {
+			expr = "^" + globToRegexp(pattern) + "$"
 		}
-		if isSequenceByForeignKey {
-			column.IsSequence = true
+		re, e := regexp.Compile(expr)
+		if nil != e {
+			return nil, errors.New("table pattern '" + pattern + "' is invalid, " + e.Error())
 		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
 
-		found := false
-		for idx, col := range columns {
-			if col.DbName == column.DbName {
-				found = true
+// splitTablePatterns 用逗号分隔表名模式, 正则表达式 /.../ 中的逗号 (如 \d{2,4})
+// 不分隔, 正则表达式中的 / 需要写成 \/
+func splitTablePatterns(s string) []string {
+	var patterns []string
+	var buf bytes.Buffer
+	inRegexp := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inRegexp && c == '\\' && i+1 < len(s):
+			buf.WriteByte(c)
+			i++
+			c = s[i]
+		case inRegexp && c == '/':
+			inRegexp = false
+		case !inRegexp && c == '/' && strings.TrimSpace(buf.String()) == "":
+			inRegexp = true
+		case !inRegexp && c == ',':
+			patterns = append(patterns, buf.String())
+			buf.Reset()
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return append(patterns, buf.String())
+}
 
-				if column.IsPrimaryKey {
-					columns[idx].IsPrimaryKey = true
-				}
-				break
-			}
+// globToRegexp 将 * 和 ? 通配符转换为正则表达式
+func globToRegexp(pattern string) string {
+	var buf bytes.Buffer
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
 		}
-		if found {
-			continue
+	}
+	return buf.String()
+}
+
+func matchTable(patterns []*regexp.Regexp, tableName string) bool {
+	for _, re := range patterns {
+		if re.MatchString(tableName) {
+			return true
 		}
+	}
+	return false
+}
 
+// initTable 根据表的列初始化表的其它属性, 列的类型不支持时返回错误
+func (cmd *dbBase) initTable(table *Table, columns []Column) error {
+	for idx := range columns {
+		column := &columns[idx]
 		column.GoName = CamelCase(column.DbName)
 		if column.GoName == "Type" {
 			column.GoName = "Typ"
 		}
 
-		if "id" == column.DbName && "int4" == column.DbType {
+		if ("id" == column.DbName || column.IsPrimaryKey && column.IsSequence) && "int4" == column.DbType {
+			column.GoType = "int64"
+		} else if column.IsForeignKey && isIntegerDbType(column.DbType) {
+			// 非整数的外键的类型在 initRelations 中取被引用的列的类型
 			column.GoType = "int64"
-		} else if column.IsForeignKey {
+		} else if "numeric" == column.DbType && 0 == column.Scale && column.Precision > 0 && column.Precision <= 18 {
 			column.GoType = "int64"
 		} else {
-			column.GoType = toGoTypeFromDbType(tableName, column.DbType)
+			goType, e := toGoTypeFromDbType(table.TableName, column.DbType)
+			if nil != e {
+				return e
+			}
+			column.GoType = goType
+		}
+	}
+	sortColumns(columns)
+
+	table.Columns = columns
+	table.IsCombinedKey, table.PrimaryKey = getPrimaryKey(table.Columns)
+	table.ClassName = Typeify(cmd.trimPrefix(table.TableName))
+
+	//if "tpt_network_devices" == table.TableName {
+	//  fmt.Println(table.TableName, table.IsCombinedKey, table.PrimaryKey)
+	//}
+	for _, column := range columns {
+		if "created_at" == column.DbName {
+			table.HasCreatedAt = true
+		}
+		if "updated_at" == column.DbName {
+			table.HasUpdatedAt = true
+		}
+	}
+	return nil
+}
+
+// initRelations 根据外键设置表的 BelongsTo 和 HasMany, 只处理引用单列主键
+// 或单列唯一索引的外键, 被引用的表不在 tables 中时忽略这个外键
+func initRelations(tables []Table) {
+	byName := map[string]int{}
+	for idx := range tables {
+		byName[tables[idx].TableName] = idx
+		tables[idx].BelongsTo = nil
+		tables[idx].HasMany = nil
+	}
+
+	for idx := range tables {
+		table := &tables[idx]
+		if table.IsView {
+			continue
+		}
+		for columnIdx := range table.Columns {
+			column := table.Columns[columnIdx]
+			if !column.IsForeignKey || column.RefTable == "" {
+				continue
+			}
+			targetIdx, ok := byName[column.RefTable]
+			if !ok {
+				continue
+			}
+			target := &tables[targetIdx]
+			targetColumn, ok := referencedColumn(target, column.RefColumn)
+			if !ok {
+				continue
+			}
+			if !isIntegerDbType(column.DbType) && column.GoType != targetColumn.GoType {
+				column.GoType = targetColumn.GoType
+				table.Columns[columnIdx].GoType = column.GoType
+				for pkIdx := range table.PrimaryKey {
+					if table.PrimaryKey[pkIdx].DbName == column.DbName {
+						table.PrimaryKey[pkIdx].GoType = column.GoType
+					}
+				}
+			}
+
+			name := CamelCase(strings.TrimSuffix(column.DbName, "_id"))
+			if column.IsPrimaryKey {
+				// 主键同时是外键时是一对一的扩展表, 用被引用的类名作为关系名
+				name = target.ClassName
+			}
+			relation := Relation{Name: name,
+				Table:        table.TableName,
+				ClassName:    table.ClassName,
+				Column:       column,
+				Target:       target.TableName,
+				TargetClass:  target.ClassName,
+				TargetColumn: targetColumn}
+			table.BelongsTo = append(table.BelongsTo, relation)
+
+			relation.Name = Pluralize(table.ClassName)
+			target.HasMany = append(target.HasMany, relation)
 		}
+	}
+}
 
-		columns = append(columns, column)
+// isIntegerDbType 判断是否为整数类型
+func isIntegerDbType(dbType string) bool {
+	switch dbType {
+	case "int2", "int4", "int8":
+		return true
+	default:
+		return false
 	}
+}
 
-	if rows.Err() != nil {
-		return nil, rows.Err()
+// referencedColumn 返回外键引用的列, 它必须是单列的主键或单列的唯一索引
+func referencedColumn(target *Table, name string) (Column, bool) {
+	if !target.IsCombinedKey && (name == "" || target.PrimaryKey[0].DbName == name) {
+		return target.PrimaryKey[0], true
+	}
+	for _, index := range target.Indexes {
+		if !index.IsUnique || len(index.Columns) != 1 || index.Columns[0] != name {
+			continue
+		}
+		for _, column := range target.Columns {
+			if column.DbName == name {
+				return column, true
+			}
+		}
 	}
+	return Column{}, false
+}
 
+// sortColumns 将 id, name, description 放在最前面, created_at 和 updated_at 放在最后面
+func sortColumns(columns []Column) {
 	moveToFirst := func(name string) {
 		for idx := range columns {
 			if columns[idx].DbName == name {
@@ -282,7 +421,7 @@ func (cmd *dbBase) getByTable(db *sql.DB, tableCatalog, tableSchema, tableName s
 					return
 				}
 				tmp := columns[idx]
-				copy(columns[1:idx], columns[0:idx-1])
+				copy(columns[1:idx+1], columns[0:idx])
 				columns[0] = tmp
 				return
 			}
@@ -310,8 +449,6 @@ func (cmd *dbBase) getByTable(db *sql.DB, tableCatalog, tableSchema, tableName s
 
 	moveToLast("created_at")
 	moveToLast("updated_at")
-
-	return columns, nil
 }
 
 // GenerateControllerCommand - 生成控制器
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestFilterTables(t *testing.T) {
+	tables := []Table{{TableName: "tpt_books"}, {TableName: "tpt_tags"}, {TableName: "sys_users"}, {TableName: "sys_logs"}}
+	cmd := dbBase{include: "tpt_*, /^sys_(users|roles)$/", exclude: "*_tags", dbPrefix: "tpt_,sys_"}
+	filtered, err := cmd.filterTables(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 2 || filtered[0].TableName != "tpt_books" || filtered[1].TableName != "sys_users" {
+		t.Error("tables is", filtered)
+	}
+	if name := cmd.trimPrefix("sys_users"); name != "users" {
+		t.Error("name is", name)
+	}
+
+	cmd.include = "/(/"
+	if _, err := cmd.filterTables(tables); err == nil {
+		t.Error("excepted error")
+	}
+
+	// 正则表达式中的逗号不分隔模式
+	tables = []Table{{TableName: "log_01"}, {TableName: "log_2020"}, {TableName: "log_1"}, {TableName: "a/b"}}
+	cmd = dbBase{include: `/^log_\d{2,4}$/, /^a\/b$/`}
+	filtered, err = cmd.filterTables(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 3 || filtered[0].TableName != "log_01" || filtered[1].TableName != "log_2020" || filtered[2].TableName != "a/b" {
+		t.Error("tables is", filtered)
+	}
+}
+
+func TestInitTableUnsupportedType(t *testing.T) {
+	for _, dbType := range []string{"tsvector", "money", "point"} {
+		cmd := dbBase{}
+		table := Table{TableName: "tpt_docs"}
+		if err := cmd.initTable(&table, []Column{{DbName: "id", DbType: "int4"}, {DbName: "doc", DbType: dbType}}); err == nil {
+			t.Error(dbType + ": excepted error")
+		} else if err.Error() != "'"+dbType+"' of 'tpt_docs' is unsupported" {
+			t.Error(dbType+":", err)
+		}
+	}
+}
@@ -1,12 +1,5 @@
 package main
 
-import (
-	"path/filepath"
-	"text/template"
-
-	"github.com/three-plus-three/gengen/types"
-)
-
 // GenerateModelsCommand - 生成数据库模型代码
 type GenerateDBObjectCommand struct {
 	baseCommand
@@ -14,26 +7,8 @@ type GenerateDBObjectCommand struct {
 
 // Run - 生成数据库模型代码
 func (cmd *GenerateDBObjectCommand) Run(args []string) error {
-	return cmd.runAll(args, cmd.generateStructs)
-}
-
-func (cmd *GenerateDBObjectCommand) generateStructs(tables []*types.ClassSpec) error {
-	funcs := template.FuncMap{
-		"omitempty": func(t types.FieldSpec) bool {
-			return !t.IsRequired
-		},
-		"tableName": getTableName}
-
-	params := map[string]interface{}{"namespace": cmd.ns,
-		"classes": tables}
-
-	return cmd.executeTempate(cmd.override, []string{"ns", "db"}, funcs, params,
-		filepath.Join(cmd.output, "db.go"))
-}
-
-func getTableName(t *types.ClassSpec) string {
-	if t.Table != "" {
-		return t.Table
+	if err := cmd.loadConfig("db"); err != nil {
+		return err
 	}
-	return Tableize(t.Name)
+	return cmd.runAll(args, "db", cmd.options())
 }
@@ -0,0 +1,1158 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadDDL 从 .sql 文件中读取表结构, files 是用逗号分隔的文件或目录, 目录下的
+// .sql 文件按文件名排序, 这样可以直接使用按顺序命名的迁移脚本
+func loadDDL(files string) ([]Table, error) {
+	var filenames []string
+	for _, name := range strings.Split(files, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		st, e := os.Stat(name)
+		if nil != e {
+			return nil, e
+		}
+		if !st.IsDir() {
+			filenames = append(filenames, name)
+			continue
+		}
+		matches, e := filepath.Glob(filepath.Join(name, "*.sql"))
+		if nil != e {
+			return nil, e
+		}
+		sort.Strings(matches)
+		filenames = append(filenames, matches...)
+	}
+
+	parser := &ddlParser{}
+	for _, filename := range filenames {
+		bs, e := ioutil.ReadFile(filename)
+		if nil != e {
+			return nil, e
+		}
+		if e := parser.parse(string(bs)); nil != e {
+			return nil, errors.New("parse '" + filename + "' fail, " + e.Error())
+		}
+	}
+	return parser.result()
+}
+
+// ddlTable 是解析过程中的表, 列和约束在所有语句都解析完后才合并到 Table 中
+type ddlTable struct {
+	Table
+	columns     []Column
+	primaryKey  []string
+	foreignKeys []ddlForeignKey
+	checks      []map[string]*checkRule
+	inheritKey  []string // INHERITS 的父表的主键, 表没有主键时使用它
+}
+
+// ddlForeignKey 外键列和它引用的表和列, refColumn 为空时引用的是表的主键
+type ddlForeignKey struct {
+	column    string
+	refTable  string
+	refColumn string
+}
+
+// ddlParser - 解析 CREATE TABLE, ALTER TABLE ... ADD 和 CREATE INDEX 语句, 其它语句将被忽略
+type ddlParser struct {
+	tables []*ddlTable
+	enums  map[string][]string // CREATE TYPE ... AS ENUM 定义的类型
+}
+
+func (p *ddlParser) table(name string) *ddlTable {
+	for _, t := range p.tables {
+		if t.TableName == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// result 返回所有的表, 表的 GoName, GoType 等还需要调用 initTable 来设置
+func (p *ddlParser) result() ([]Table, error) {
+	tables := make([]Table, 0, len(p.tables))
+	for _, t := range p.tables {
+		for idx := range t.columns {
+			column := &t.columns[idx]
+			for _, name := range t.primaryKey {
+				if name == column.DbName {
+					column.IsPrimaryKey = true
+					column.IsNullable = false
+				}
+			}
+			for _, fk := range t.foreignKeys {
+				if fk.column == column.DbName {
+					column.IsForeignKey = true
+					column.RefTable = fk.refTable
+					column.RefColumn = fk.refColumn
+				}
+			}
+			for _, rules := range t.checks {
+				if rule := rules[column.DbName]; rule != nil {
+					rule.apply(column)
+				}
+			}
+		}
+		if _, pk := getPrimaryKey(t.columns); len(pk) == 0 {
+			for idx := range t.columns {
+				for _, name := range t.inheritKey {
+					if name == t.columns[idx].DbName {
+						t.columns[idx].IsPrimaryKey = true
+						t.columns[idx].IsNullable = false
+					}
+				}
+			}
+		}
+		table := t.Table
+		table.Columns = t.columns
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (p *ddlParser) parse(text string) error {
+	tokens, e := tokenizeSQL(text)
+	if nil != e {
+		return e
+	}
+
+	for len(tokens) > 0 {
+		end := 0
+		depth := 0
+		for ; end < len(tokens); end++ {
+			if tokens[end].is("(") {
+				depth++
+			} else if tokens[end].is(")") {
+				depth--
+			} else if tokens[end].is(";") && depth == 0 {
+				break
+			}
+		}
+
+		stmt := &tokenStream{tokens: tokens[:end]}
+		if end < len(tokens) {
+			end++
+		}
+		tokens = tokens[end:]
+
+		if e := p.statement(stmt); nil != e {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *ddlParser) statement(s *tokenStream) error {
+	switch {
+	case s.accept("CREATE"):
+		s.accept("OR", "REPLACE")
+		s.accept("TEMP")
+		s.accept("TEMPORARY")
+		s.accept("UNLOGGED")
+		if s.accept("TABLE") {
+			return p.createTable(s)
+		}
+		if s.accept("TYPE") {
+			return p.createType(s)
+		}
+		if s.accept("UNIQUE", "INDEX") {
+			return p.createIndex(s, true)
+		}
+		if s.accept("INDEX") {
+			return p.createIndex(s, false)
+		}
+		return nil
+	case s.accept("ALTER", "TABLE"):
+		return p.alterTable(s)
+	case s.accept("COMMENT", "ON"):
+		return p.comment(s)
+	default:
+		return nil
+	}
+}
+
+// createType 解析 CREATE TYPE name AS ENUM ('a', 'b'), 其它类型将被忽略
+func (p *ddlParser) createType(s *tokenStream) error {
+	_, name, e := s.qualifiedName()
+	if nil != e {
+		return e
+	}
+	if !s.accept("AS", "ENUM") || !s.accept("(") {
+		return nil
+	}
+	var values []string
+	for !s.eof() && !s.peek().is(")") {
+		if t := s.next(); t.kind == tokenString {
+			values = append(values, t.text)
+		}
+	}
+	if p.enums == nil {
+		p.enums = map[string][]string{}
+	}
+	p.enums[strings.ToLower(name)] = values
+	return nil
+}
+
+func (p *ddlParser) createTable(s *tokenStream) error {
+	ifNotExists := s.accept("IF", "NOT", "EXISTS")
+	schema, name, e := s.qualifiedName()
+	if nil != e {
+		return e
+	}
+	if s.accept("AS") {
+		return nil
+	}
+	if p.table(name) != nil {
+		// 与数据库一样, 有 IF NOT EXISTS 时忽略已存在的表, 否则报错
+		if ifNotExists {
+			return nil
+		}
+		return errors.New("table '" + name + "' already exists")
+	}
+	if !s.accept("(") {
+		return errors.New("'(' is missing after 'CREATE TABLE " + name + "'")
+	}
+
+	t := &ddlTable{Table: Table{Schema: schema, TableName: name}}
+	p.tables = append(p.tables, t)
+
+	for {
+		element := s.until(",", ")")
+		if len(element.tokens) > 0 {
+			if e := p.tableElement(t, element); nil != e {
+				return errors.New("table '" + name + "': " + e.Error())
+			}
+		}
+		if s.accept(")") {
+			break
+		}
+		if !s.accept(",") {
+			return errors.New("table '" + name + "': ')' is missing")
+		}
+	}
+
+	// MySQL 的表选项, 如 ENGINE=InnoDB COMMENT='图书', 或 PostgreSQL 的 INHERITS (parent)
+	for !s.eof() {
+		if s.accept("INHERITS") {
+			parents, e := s.nameList()
+			if nil != e {
+				return errors.New("table '" + name + "': " + e.Error())
+			}
+			if e := p.inherit(t, parents); nil != e {
+				return errors.New("table '" + name + "': " + e.Error())
+			}
+			continue
+		}
+		if s.accept("COMMENT") {
+			s.accept("=")
+			if c := s.next(); c.kind == tokenString {
+				t.Comment = c.text
+			}
+			continue
+		}
+		s.next()
+	}
+	return nil
+}
+
+// inherit 将父表的列放到子表的列之前, 与 PostgreSQL 一样, 子表不会继承父表的主键和外键
+// 约束, 但子表没有主键时仍然用第一个父表的主键作为它的主键
+func (p *ddlParser) inherit(t *ddlTable, parents []string) error {
+	var columns []Column
+	for idx, name := range parents {
+		parent := p.table(name)
+		if parent == nil {
+			return errors.New("parent table '" + name + "' isn't found")
+		}
+
+		var key []string
+		for _, column := range parent.columns {
+			if column.IsPrimaryKey {
+				key = append(key, column.DbName)
+			}
+			column.IsPrimaryKey = false
+			column.IsForeignKey = false
+			column.RefTable = ""
+			column.RefColumn = ""
+			columns = append(columns, column)
+		}
+		key = append(key, parent.primaryKey...)
+		if len(key) == 0 {
+			key = parent.inheritKey
+		}
+		if idx == 0 {
+			t.inheritKey = key
+		}
+	}
+	t.columns = append(columns, t.columns...)
+	return nil
+}
+
+// comment 解析 COMMENT ON TABLE name IS '...' 和 COMMENT ON COLUMN table.column IS '...'
+func (p *ddlParser) comment(s *tokenStream) error {
+	switch {
+	case s.accept("TABLE"):
+		_, name, e := s.qualifiedName()
+		if nil != e {
+			return e
+		}
+		t := p.table(name)
+		if t == nil {
+			return errors.New("table '" + name + "' isn't found in 'COMMENT ON TABLE'")
+		}
+		t.Comment = commentText(s)
+	case s.accept("COLUMN"):
+		var names []string
+		for {
+			name := s.next()
+			if name.kind != tokenIdent {
+				return errors.New("column name is missing in 'COMMENT ON COLUMN'")
+			}
+			names = append(names, name.text)
+			if !s.accept(".") {
+				break
+			}
+		}
+		if len(names) < 2 {
+			return errors.New("table name is missing in 'COMMENT ON COLUMN " + names[0] + "'")
+		}
+		tableName, columnName := names[len(names)-2], names[len(names)-1]
+		t := p.table(tableName)
+		if t == nil {
+			return errors.New("table '" + tableName + "' isn't found in 'COMMENT ON COLUMN'")
+		}
+		for idx := range t.columns {
+			if t.columns[idx].DbName == columnName {
+				t.columns[idx].Comment = commentText(s)
+				return nil
+			}
+		}
+		return errors.New("column '" + columnName + "' isn't found in table '" + tableName + "'")
+	}
+	return nil
+}
+
+// commentText 读取 IS '...', IS NULL 时返回空字符串
+func commentText(s *tokenStream) string {
+	if !s.accept("IS") {
+		return ""
+	}
+	if c := s.next(); c.kind == tokenString {
+		return c.text
+	}
+	return ""
+}
+
+// tableElement 解析 CREATE TABLE 中的一个列或约束
+func (p *ddlParser) tableElement(t *ddlTable, s *tokenStream) error {
+	var constraintName string
+	if s.accept("CONSTRAINT") {
+		constraintName = s.next().text
+	}
+
+	switch {
+	case s.accept("PRIMARY", "KEY"):
+		columns, e := s.nameList()
+		if nil != e {
+			return e
+		}
+		t.primaryKey = append(t.primaryKey, columns...)
+		return nil
+	case s.accept("FOREIGN", "KEY"):
+		columns, e := s.nameList()
+		if nil != e {
+			return e
+		}
+		var refTable string
+		var refColumns []string
+		if s.accept("REFERENCES") {
+			refTable, refColumns, e = s.references()
+			if nil != e {
+				return e
+			}
+		}
+		for idx, column := range columns {
+			fk := ddlForeignKey{column: column, refTable: refTable}
+			if idx < len(refColumns) {
+				fk.refColumn = refColumns[idx]
+			}
+			t.foreignKeys = append(t.foreignKeys, fk)
+		}
+		return nil
+	case s.accept("UNIQUE"):
+		return p.tableIndex(t, s, constraintName, true)
+	case s.accept("INDEX"), s.accept("KEY"):
+		return p.tableIndex(t, s, constraintName, false)
+	case s.accept("CHECK"):
+		start := s.pos
+		s.skipGroup()
+		if rules := checkRules(s.tokens[start:s.pos]); rules != nil {
+			t.checks = append(t.checks, rules)
+		}
+		return nil
+	case s.accept("EXCLUDE"):
+		return nil
+	}
+	return p.columnDefinition(t, s)
+}
+
+// tableIndex 解析 CREATE TABLE 中的 UNIQUE [KEY] [name] (columns) 或 MySQL 的 KEY name (columns),
+// name 为 CONSTRAINT 中的约束名, 索引没有名称时用它作为索引的名称
+func (p *ddlParser) tableIndex(t *ddlTable, s *tokenStream, name string, isUnique bool) error {
+	if isUnique && !s.accept("KEY") {
+		s.accept("INDEX")
+	}
+	if s.peek().kind == tokenIdent {
+		name = s.next().text
+	}
+	columns, e := s.nameList()
+	if nil != e {
+		return e
+	}
+	t.Indexes = append(t.Indexes, Index{Name: name, Columns: columns, IsUnique: isUnique})
+	return nil
+}
+
+func (p *ddlParser) columnDefinition(t *ddlTable, s *tokenStream) error {
+	name := s.next()
+	if name.kind != tokenIdent {
+		return errors.New("column name is missing")
+	}
+
+	typeName, e := s.typeName()
+	if nil != e {
+		return errors.New("column '" + name.text + "': " + e.Error())
+	}
+	column := Column{DbName: name.text, IsNullable: true}
+	if values, ok := p.enums[strings.ToLower(typeName)]; ok {
+		column.DbType = "enum"
+		column.EnumValues = values
+	} else {
+		column.DbType, column.IsSequence, e = toDbTypeFromDDL(typeName)
+		if nil != e {
+			return errors.New("column '" + name.text + "': " + e.Error())
+		}
+		column.Precision, column.Scale, column.EnumValues = typeArgs(column.DbType, typeName)
+		column.MaxLength = typeLength(column.DbType, typeName)
+	}
+
+	var constraintName string
+	for !s.eof() {
+		switch {
+		case s.accept("CONSTRAINT"):
+			constraintName = s.next().text
+			continue
+		case s.accept("NOT", "NULL"):
+			column.IsNullable = false
+		case s.accept("UNIQUE"):
+			s.accept("KEY")
+			t.Indexes = append(t.Indexes, Index{Name: constraintName, Columns: []string{name.text}, IsUnique: true})
+		case s.accept("PRIMARY", "KEY"):
+			column.IsPrimaryKey = true
+			column.IsNullable = false
+		case s.accept("REFERENCES"):
+			column.IsForeignKey = true
+			refTable, refColumns, e := s.references()
+			if nil != e {
+				return errors.New("column '" + name.text + "': " + e.Error())
+			}
+			column.RefTable = refTable
+			if len(refColumns) > 0 {
+				column.RefColumn = refColumns[0]
+			}
+		case s.accept("CHECK"):
+			start := s.pos
+			s.skipGroup()
+			if rules := checkRules(s.tokens[start:s.pos]); rules != nil {
+				t.checks = append(t.checks, rules)
+			}
+		case s.accept("COMMENT"):
+			if c := s.next(); c.kind == tokenString {
+				column.Comment = c.text
+			}
+		case s.accept("AUTO_INCREMENT"), s.accept("AUTOINCREMENT"):
+			column.IsSequence = true
+		case s.accept("GENERATED"):
+			for !s.eof() && !s.peek().is("IDENTITY") && !s.peek().is("(") {
+				s.next()
+			}
+			if s.accept("IDENTITY") {
+				column.IsSequence = true
+			}
+		case s.accept("DEFAULT"):
+			if s.peek().is("NEXTVAL") {
+				column.IsSequence = true
+			}
+			start := s.pos
+			if s.peek().is("(") {
+				s.skipGroup()
+			} else {
+				s.accept("-")
+				s.next()
+			}
+			for s.accept("::") {
+				s.typeName()
+			}
+			column.DefaultValue = defaultValue(s.tokens[start:s.pos])
+		default:
+			s.next()
+		}
+		constraintName = ""
+	}
+	t.columns = append(t.columns, column)
+	return nil
+}
+
+func (p *ddlParser) alterTable(s *tokenStream) error {
+	s.accept("IF", "EXISTS")
+	s.accept("ONLY")
+	_, name, e := s.qualifiedName()
+	if nil != e {
+		return e
+	}
+	t := p.table(name)
+	if t == nil {
+		return errors.New("table '" + name + "' isn't found in 'ALTER TABLE'")
+	}
+
+	for !s.eof() {
+		action := s.until(",")
+		s.accept(",")
+		if !action.accept("ADD") {
+			continue
+		}
+		if action.accept("COLUMN") {
+			action.accept("IF", "NOT", "EXISTS")
+			if e := p.columnDefinition(t, action); nil != e {
+				return errors.New("table '" + name + "': " + e.Error())
+			}
+			continue
+		}
+		if e := p.tableElement(t, action); nil != e {
+			return errors.New("table '" + name + "': " + e.Error())
+		}
+	}
+	return nil
+}
+
+func (p *ddlParser) createIndex(s *tokenStream, isUnique bool) error {
+	s.accept("CONCURRENTLY")
+	s.accept("IF", "NOT", "EXISTS")
+	var indexName string
+	if !s.peek().is("ON") {
+		_, indexName, _ = s.qualifiedName()
+	}
+	if !s.accept("ON") {
+		return errors.New("'ON' is missing in 'CREATE INDEX " + indexName + "'")
+	}
+	s.accept("ONLY")
+	_, tableName, e := s.qualifiedName()
+	if nil != e {
+		return e
+	}
+	if s.accept("USING") {
+		s.next()
+	}
+	columns, e := s.nameList()
+	if nil != e {
+		return errors.New("index '" + indexName + "': " + e.Error())
+	}
+
+	t := p.table(tableName)
+	if t == nil {
+		return errors.New("table '" + tableName + "' isn't found in 'CREATE INDEX " + indexName + "'")
+	}
+	t.Indexes = append(t.Indexes, Index{Name: indexName, Columns: columns, IsUnique: isUnique})
+	return nil
+}
+
+// toDbTypeFromDDL 将 DDL 中的类型转换为 PostgreSQL 的 udt_name, 并返回是否是自增类型
+func toDbTypeFromDDL(typeName string) (string, bool, error) {
+	typeName = strings.ToLower(typeName)
+	if strings.HasSuffix(typeName, "[]") {
+		elem, _, e := toDbTypeFromDDL(strings.TrimSuffix(typeName, "[]"))
+		if nil != e {
+			return "", false, e
+		}
+		switch elem {
+		case "int2", "int4", "int8", "float4", "float8", "numeric", "varchar", "text", "bpchar", "uuid", "bool":
+			return "_" + elem, false, nil
+		}
+		return "", false, errors.New("type '" + typeName + "' is unsupported")
+	}
+	fullName := typeName
+	if idx := strings.Index(typeName, "("); idx >= 0 {
+		typeName = strings.TrimSpace(typeName[:idx]) + typeName[strings.LastIndex(typeName, ")")+1:]
+	}
+	var words []string
+	unsigned := false
+	for _, word := range strings.Fields(typeName) {
+		switch word {
+		case "unsigned":
+			unsigned = true
+		case "signed", "zerofill":
+		default:
+			words = append(words, word)
+		}
+	}
+	typeName = strings.Join(words, " ")
+	if unsigned && (typeName == "int" || typeName == "integer") {
+		return "int8", false, nil
+	}
+
+	switch typeName {
+	case "serial", "serial4":
+		return "int4", true, nil
+	case "smallserial", "serial2":
+		return "int2", true, nil
+	case "bigserial", "serial8":
+		return "int8", true, nil
+	case "int", "integer", "int4", "mediumint", "tinyint":
+		return "int4", false, nil
+	case "smallint", "int2":
+		return "int2", false, nil
+	case "bigint", "int8":
+		return "int8", false, nil
+	case "bool", "boolean":
+		return "bool", false, nil
+	case "real", "float4":
+		return "float4", false, nil
+	case "float":
+		// PostgreSQL 中 float 和 float(25) 到 float(53) 是 double precision, 只有 float(1) 到 float(24) 是 real
+		if precision, _, _ := typeArgs("float", fullName); precision > 0 && precision <= 24 {
+			return "float4", false, nil
+		}
+		return "float8", false, nil
+	case "double precision", "double", "float8":
+		return "float8", false, nil
+	case "numeric", "decimal":
+		return "numeric", false, nil
+	case "varchar", "character varying", "nvarchar":
+		return "varchar", false, nil
+	case "char", "character", "bpchar":
+		return "bpchar", false, nil
+	case "text", "tinytext", "mediumtext", "longtext", "clob":
+		return "text", false, nil
+	case "timestamp", "timestamp without time zone", "datetime":
+		return "timestamp", false, nil
+	case "timestamptz", "timestamp with time zone":
+		return "timestamptz", false, nil
+	case "time", "time without time zone":
+		return "time", false, nil
+	case "timetz", "time with time zone":
+		return "timetz", false, nil
+	case "bytea", "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary":
+		return "bytea", false, nil
+	case "enum":
+		return "enum", false, nil
+	case "date", "json", "jsonb", "cidr", "inet", "macaddr", "uuid", "interval", "hstore":
+		return typeName, false, nil
+	default:
+		return "", false, errors.New("type '" + typeName + "' is unsupported")
+	}
+}
+
+// typeArgs 从类型的参数中读取 numeric(10, 2) 和 float(24) 的精度和小数位数, 或 MySQL 的 enum('a', 'b') 的值
+func typeArgs(dbType, typeName string) (precision, scale int, values []string) {
+	start := strings.Index(typeName, "(")
+	end := strings.LastIndex(typeName, ")")
+	if start < 0 || end < start {
+		return 0, 0, nil
+	}
+	args := splitTypeArgs(typeName[start+1 : end])
+	switch dbType {
+	case "numeric", "float":
+		if len(args) > 0 {
+			precision, _ = strconv.Atoi(args[0])
+		}
+		if len(args) > 1 {
+			scale, _ = strconv.Atoi(args[1])
+		}
+	case "enum":
+		values = args
+	}
+	return precision, scale, values
+}
+
+// typeLength 从类型的参数中读取 varchar(20) 和 char(3) 的长度
+func typeLength(dbType, typeName string) int {
+	switch dbType {
+	case "varchar", "bpchar":
+		start := strings.Index(typeName, "(")
+		end := strings.LastIndex(typeName, ")")
+		if start < 0 || end < start {
+			return 0
+		}
+		length, _ := strconv.Atoi(strings.TrimSpace(typeName[start+1 : end]))
+		return length
+	default:
+		return 0
+	}
+}
+
+// splitTypeArgs 用逗号分隔类型的参数, 参数可以是用单引号括起来的字符串
+func splitTypeArgs(text string) []string {
+	var args []string
+	var buf []byte
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(text) && text[i+1] == '\'':
+			buf = append(buf, c)
+			i++
+		case c == '\'':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			args = append(args, strings.TrimSpace(string(buf)))
+			buf = buf[:0]
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(args, strings.TrimSpace(string(buf)))
+}
+
+// checkRule 从 CHECK 约束中得到的一个列的取值范围
+type checkRule struct {
+	values   []string
+	minValue string
+	maxValue string
+}
+
+func (rule *checkRule) apply(column *Column) {
+	if len(rule.values) > 0 {
+		column.CheckValues = rule.values
+	}
+	if rule.minValue != "" {
+		column.MinValue = rule.minValue
+	}
+	if rule.maxValue != "" {
+		column.MaxValue = rule.maxValue
+	}
+}
+
+// parseCheck 解析 CHECK 约束, 如 PostgreSQL 的 pg_get_constraintdef 返回的内容
+func parseCheck(expr string) map[string]*checkRule {
+	// PostgreSQL 将 col IN ('a', 'b') 转换为 col = ANY (ARRAY['a', 'b'])
+	expr = strings.Replace(expr, "[]", "", -1)
+	expr = strings.Replace(expr, "ARRAY[", "(", -1)
+	expr = strings.Replace(expr, "]", ")", -1)
+	tokens, e := tokenizeSQL(expr)
+	if nil != e {
+		return nil
+	}
+	return checkRules(tokens)
+}
+
+// checkRules 从 CHECK 约束中读取 col IN (...), col = ANY (ARRAY[...]), col BETWEEN a AND b,
+// col >= a 和 col <= b, 多个条件只能用 AND 连接, 有其它形式的条件时返回 nil
+func checkRules(tokens []sqlToken) map[string]*checkRule {
+	s := &tokenStream{tokens: plainTokens(tokens)}
+	s.accept("CHECK")
+
+	rules := map[string]*checkRule{}
+	for {
+		name := s.next()
+		if name.kind != tokenIdent || !name.quoted && columnConstraints[strings.ToUpper(name.text)] {
+			return nil
+		}
+		rule := rules[name.text]
+		if rule == nil {
+			rule = &checkRule{}
+			rules[name.text] = rule
+		}
+
+		switch {
+		case s.accept("IN"), s.accept("=", "ANY"):
+			for {
+				value, ok := checkValue(s)
+				if !ok {
+					return nil
+				}
+				rule.values = append(rule.values, value)
+				if !s.accept(",") {
+					break
+				}
+			}
+		case s.accept("BETWEEN"):
+			minValue, ok := checkValue(s)
+			if !ok || !s.accept("AND") {
+				return nil
+			}
+			maxValue, ok := checkValue(s)
+			if !ok {
+				return nil
+			}
+			rule.minValue, rule.maxValue = minValue, maxValue
+		case s.accept(">", "="):
+			value, ok := checkValue(s)
+			if !ok {
+				return nil
+			}
+			rule.minValue = value
+		case s.accept("<", "="):
+			value, ok := checkValue(s)
+			if !ok {
+				return nil
+			}
+			rule.maxValue = value
+		default:
+			return nil
+		}
+
+		if s.eof() {
+			return rules
+		}
+		if !s.accept("AND") {
+			return nil
+		}
+	}
+}
+
+// checkValue 读取一个字符串或数字常量
+func checkValue(s *tokenStream) (string, bool) {
+	negative := s.accept("-")
+	t := s.next()
+	switch {
+	case t.kind == tokenNumber && negative:
+		return "-" + t.text, true
+	case t.kind == tokenNumber, t.kind == tokenString && !negative:
+		return t.text, true
+	default:
+		return "", false
+	}
+}
+
+// defaultValue 返回缺省值中的常量, 缺省值为函数调用或表达式时返回空字符串
+func defaultValue(tokens []sqlToken) string {
+	tokens = plainTokens(tokens)
+	switch {
+	case len(tokens) == 1 && (tokens[0].kind == tokenString || tokens[0].kind == tokenNumber):
+		return tokens[0].text
+	case len(tokens) == 1 && (tokens[0].is("TRUE") || tokens[0].is("FALSE")):
+		return strings.ToLower(tokens[0].text)
+	case len(tokens) == 2 && tokens[0].is("-") && tokens[1].kind == tokenNumber:
+		return "-" + tokens[1].text
+	default:
+		return ""
+	}
+}
+
+// plainTokens 去掉括号和 ::type 形式的类型转换
+func plainTokens(tokens []sqlToken) []sqlToken {
+	var results []sqlToken
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.is("(") || t.is(")"):
+		case t.is("::"):
+			for i+1 < len(tokens) && tokens[i+1].kind == tokenIdent && !tokens[i+1].is("AND") && !tokens[i+1].is("OR") {
+				i++
+			}
+		default:
+			results = append(results, t)
+		}
+	}
+	return results
+}
+
+const (
+	tokenIdent = iota
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type sqlToken struct {
+	kind   int
+	text   string
+	quoted bool // 是否是用引号括起来的标识符, 它不能作为关键字
+}
+
+// is 判断是不是关键字或标点 word, 关键字不区分大小写
+func (t sqlToken) is(word string) bool {
+	switch t.kind {
+	case tokenIdent:
+		return !t.quoted && strings.EqualFold(t.text, word)
+	case tokenPunct:
+		return t.text == word
+	default:
+		return false
+	}
+}
+
+// tokenizeSQL 将 SQL 分解为标识符, 字符串, 数字和标点, 并去掉注释
+func tokenizeSQL(text string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '-' && strings.HasPrefix(text[i:], "--"), c == '#':
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("comment isn't closed")
+			}
+			i += end + 4
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				if i+1 < len(text) && text[i+1] == ']' {
+					tokens = append(tokens, sqlToken{kind: tokenPunct, text: "[]"})
+					i += 2
+					continue
+				}
+				closing = ']'
+			}
+			end := strings.IndexByte(text[i+1:], closing)
+			if end < 0 {
+				return nil, errors.New("identifier isn't closed")
+			}
+			tokens = append(tokens, sqlToken{kind: tokenIdent, text: text[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case c == '\'':
+			j := i + 1
+			for ; j < len(text); j++ {
+				if text[j] == '\'' {
+					if j+1 < len(text) && text[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(text) {
+				return nil, errors.New("string isn't closed")
+			}
+			tokens = append(tokens, sqlToken{kind: tokenString, text: strings.Replace(text[i+1:j], "''", "'", -1)})
+			i = j + 1
+		case isIdentChar(c) && !(c >= '0' && c <= '9'):
+			j := i
+			for j < len(text) && isIdentChar(text[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: tokenIdent, text: text[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(text) && (text[j] >= '0' && text[j] <= '9' || text[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: tokenNumber, text: text[i:j]})
+			i = j
+		case c == ':' && strings.HasPrefix(text[i:], "::"):
+			tokens = append(tokens, sqlToken{kind: tokenPunct, text: "::"})
+			i += 2
+		default:
+			tokens = append(tokens, sqlToken{kind: tokenPunct, text: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+type tokenStream struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (s *tokenStream) eof() bool {
+	return s.pos >= len(s.tokens)
+}
+
+func (s *tokenStream) peek() sqlToken {
+	if s.eof() {
+		return sqlToken{kind: tokenPunct}
+	}
+	return s.tokens[s.pos]
+}
+
+func (s *tokenStream) next() sqlToken {
+	t := s.peek()
+	if !s.eof() {
+		s.pos++
+	}
+	return t
+}
+
+// accept 当接下来的 token 依次为 words 时跳过它们并返回 true
+func (s *tokenStream) accept(words ...string) bool {
+	if s.pos+len(words) > len(s.tokens) {
+		return false
+	}
+	for idx, word := range words {
+		if !s.tokens[s.pos+idx].is(word) {
+			return false
+		}
+	}
+	s.pos += len(words)
+	return true
+}
+
+// until 返回到 stops 中任意一个标点 (不在括号中) 之前的 token, 不包括这个标点
+func (s *tokenStream) until(stops ...string) *tokenStream {
+	start := s.pos
+	depth := 0
+	for ; !s.eof(); s.pos++ {
+		t := s.tokens[s.pos]
+		if depth == 0 {
+			stopped := false
+			for _, stop := range stops {
+				if t.is(stop) {
+					stopped = true
+					break
+				}
+			}
+			if stopped {
+				break
+			}
+		}
+		if t.is("(") {
+			depth++
+		} else if t.is(")") {
+			depth--
+		}
+	}
+	return &tokenStream{tokens: s.tokens[start:s.pos]}
+}
+
+// skipGroup 跳过一个括号及其中的内容
+func (s *tokenStream) skipGroup() {
+	if !s.accept("(") {
+		return
+	}
+	s.until(")")
+	s.accept(")")
+}
+
+// qualifiedName 读取 [schema.]name
+func (s *tokenStream) qualifiedName() (string, string, error) {
+	t := s.next()
+	if t.kind != tokenIdent {
+		return "", "", errors.New("name is missing, actual is '" + t.text + "'")
+	}
+	if !s.accept(".") {
+		return "", t.text, nil
+	}
+	name := s.next()
+	if name.kind != tokenIdent {
+		return "", "", errors.New("name is missing after '" + t.text + ".'")
+	}
+	return t.text, name.text, nil
+}
+
+// references 读取 REFERENCES 之后的 [schema.]table [(columns)]
+func (s *tokenStream) references() (string, []string, error) {
+	_, table, e := s.qualifiedName()
+	if nil != e {
+		return "", nil, e
+	}
+	if !s.peek().is("(") {
+		return table, nil, nil
+	}
+	columns, e := s.nameList()
+	return table, columns, e
+}
+
+// nameList 读取 (a, b, c), 每一项只取第一个标识符, 如 (name ASC, lower(email)) 返回 name 和 lower
+func (s *tokenStream) nameList() ([]string, error) {
+	if !s.accept("(") {
+		return nil, errors.New("'(' is missing")
+	}
+	var names []string
+	for {
+		item := s.until(",", ")")
+		for !item.eof() {
+			t := item.next()
+			if t.kind == tokenIdent {
+				if item.accept("(") {
+					if arg := item.next(); arg.kind == tokenIdent {
+						t = arg
+					}
+				}
+				names = append(names, t.text)
+				break
+			}
+		}
+		if s.accept(")") {
+			return names, nil
+		}
+		if !s.accept(",") {
+			return nil, errors.New("')' is missing")
+		}
+	}
+}
+
+// columnConstraints - 列定义中类型之后可能出现的关键字
+var columnConstraints = map[string]bool{
+	"NOT": true, "NULL": true, "PRIMARY": true, "REFERENCES": true, "DEFAULT": true,
+	"UNIQUE": true, "CHECK": true, "CONSTRAINT": true, "AUTO_INCREMENT": true,
+	"AUTOINCREMENT": true, "GENERATED": true, "COLLATE": true, "COMMENT": true,
+	"ON": true,
+}
+
+// typeName 读取列的类型, 如 varchar(20), double precision, timestamp with time zone
+func (s *tokenStream) typeName() (string, error) {
+	var parts []string
+	for !s.eof() {
+		t := s.peek()
+		switch {
+		case t.kind == tokenIdent && !t.quoted && !columnConstraints[strings.ToUpper(t.text)]:
+			if len(parts) > 0 && strings.EqualFold(t.text, "character") {
+				return strings.Join(parts, " "), nil // 如 varchar(20) CHARACTER SET utf8
+			}
+			parts = append(parts, t.text)
+			s.next()
+		case t.is("(") && len(parts) > 0:
+			s.next()
+			group := s.until(")")
+			s.accept(")")
+			var args []string
+			for _, arg := range group.tokens {
+				if arg.kind == tokenString {
+					args = append(args, "'"+strings.Replace(arg.text, "'", "''", -1)+"'")
+				} else {
+					args = append(args, arg.text)
+				}
+			}
+			parts[len(parts)-1] += "(" + strings.Join(args, "") + ")"
+		case t.is("[]") && len(parts) > 0:
+			parts[len(parts)-1] += "[]"
+			s.next()
+		default:
+			if len(parts) == 0 {
+				return "", errors.New("type is missing")
+			}
+			return strings.Join(parts, " "), nil
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.New("type is missing")
+	}
+	return strings.Join(parts, " "), nil
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDDL = `
+-- 作者
+CREATE TABLE IF NOT EXISTS public.tpt_authors (
+  id   serial PRIMARY KEY,
+  name character varying(100) NOT NULL
+);
+
+/* 图书 */
+CREATE TABLE "tpt_books" (
+  id         bigint GENERATED BY DEFAULT AS IDENTITY,
+  name       varchar(100) NOT NULL CHECK (name IS NOT NULL),
+  author_id  integer,
+  price      numeric(10, 2) DEFAULT 0,
+  published  boolean NOT NULL DEFAULT false,
+  created_at timestamp with time zone DEFAULT now(),
+  CONSTRAINT tpt_books_pkey PRIMARY KEY (id),
+  UNIQUE (name, author_id)
+);
+
+ALTER TABLE ONLY tpt_books
+  ADD CONSTRAINT tpt_books_author_fk FOREIGN KEY (author_id) REFERENCES tpt_authors(id) ON DELETE CASCADE;
+CREATE INDEX tpt_books_created_at_idx ON tpt_books USING btree (created_at DESC);
+
+CREATE TABLE tpt_tags (
+  ` + "`book_id`" + ` int(11) unsigned NOT NULL,
+  tag varchar(20) CHARACTER SET utf8 NOT NULL,
+  PRIMARY KEY (book_id, tag),
+  KEY tpt_tags_tag (tag)
+) ENGINE=InnoDB;
+
+INSERT INTO tpt_tags VALUES (1, 'a;b');
+`
+
+func TestParseDDL(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(testDDL); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 3 {
+		t.Fatal("tables is", tables)
+	}
+
+	cmd := dbBase{dbPrefix: "tpt_"}
+	for idx := range tables {
+		if err := cmd.initTable(&tables[idx], tables[idx].Columns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	authors := tables[0]
+	if authors.Schema != "public" || len(authors.PrimaryKey) != 1 || !authors.PrimaryKey[0].IsSequence {
+		t.Error("authors is", authors)
+	}
+
+	books := tables[1]
+	if books.IsCombinedKey || len(books.PrimaryKey) != 1 || !books.PrimaryKey[0].IsSequence {
+		t.Error("primary key of books is", books.PrimaryKey)
+	}
+	excepted := []struct {
+		name, dbType, goType string
+		nullable, foreignKey bool
+	}{
+		{"id", "int8", "int64", false, false},
+		{"name", "varchar", "string", false, false},
+		{"author_id", "int4", "int64", true, true},
+		{"price", "numeric", "float64", true, false},
+		{"published", "bool", "bool", false, false},
+		{"created_at", "timestamptz", "time.Time", true, false},
+	}
+	if len(books.Columns) != len(excepted) {
+		t.Fatal("columns is", books.Columns)
+	}
+	for idx, column := range books.Columns {
+		e := excepted[idx]
+		if column.DbName != e.name || column.DbType != e.dbType || column.GoType != e.goType ||
+			column.IsNullable != e.nullable || column.IsForeignKey != e.foreignKey {
+			t.Errorf("column %d excepted %v, actual %#v", idx, e, column)
+		}
+	}
+	if len(books.Indexes) != 2 ||
+		!books.Indexes[0].IsUnique || len(books.Indexes[0].Columns) != 2 ||
+		books.Indexes[1].IsUnique || books.Indexes[1].Name != "tpt_books_created_at_idx" ||
+		len(books.Indexes[1].Columns) != 1 || books.Indexes[1].Columns[0] != "created_at" {
+		t.Error("indexes of books is", books.Indexes)
+	}
+
+	initRelations(tables)
+	if belongsTo := tables[1].BelongsTo; len(belongsTo) != 1 || belongsTo[0].Name != "Author" ||
+		belongsTo[0].TargetClass != "Author" || belongsTo[0].TargetColumn.DbName != "id" {
+		t.Error("belongsTo of books is", belongsTo)
+	}
+	if hasMany := tables[0].HasMany; len(hasMany) != 1 || hasMany[0].Name != "Books" ||
+		hasMany[0].Column.DbName != "author_id" {
+		t.Error("hasMany of authors is", hasMany)
+	}
+
+	tags := tables[2]
+	if !tags.IsCombinedKey || len(tags.PrimaryKey) != 2 || tags.PrimaryKey[0].GoType != "int64" {
+		t.Error("primary key of tags is", tags.PrimaryKey)
+	}
+	if len(tags.Indexes) != 1 || tags.Indexes[0].Name != "tpt_tags_tag" {
+		t.Error("indexes of tags is", tags.Indexes)
+	}
+
+	if err := (&ddlParser{}).parse("CREATE TABLE t (geo geometry)"); err == nil {
+		t.Error("excepted error")
+	}
+}
+
+func TestParseDDLTypes(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(`CREATE TYPE mood AS ENUM ('sad', 'it''s ok');
+CREATE TABLE t (
+  a uuid, b int4[], c numeric(12), d numeric(10, 2), e mood,
+  f enum('x', 'y,z'), g smallint, h bytea, i hstore, j varchar(10)[],
+  k float, l float(24), m float(25), n real
+);`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := dbBase{}
+	if err := cmd.initTable(&tables[0], tables[0].Columns); err != nil {
+		t.Fatal(err)
+	}
+
+	excepted := []struct {
+		dbType, goType string
+		values         []string
+	}{
+		{"uuid", "string", nil},
+		{"_int4", "[]int64", nil},
+		{"numeric", "int64", nil},
+		{"numeric", "float64", nil},
+		{"enum", "string", []string{"sad", "it's ok"}},
+		{"enum", "string", []string{"x", "y,z"}},
+		{"int2", "int16", nil},
+		{"bytea", "[]byte", nil},
+		{"hstore", "hstore.Hstore", nil},
+		{"_varchar", "[]string", nil},
+		{"float8", "float64", nil},
+		{"float4", "float32", nil},
+		{"float8", "float64", nil},
+		{"float4", "float32", nil},
+	}
+	if len(tables[0].Columns) != len(excepted) {
+		t.Fatal("columns is", tables[0].Columns)
+	}
+	for idx, column := range tables[0].Columns {
+		e := excepted[idx]
+		if column.DbType != e.dbType || column.GoType != e.goType ||
+			strings.Join(column.EnumValues, "|") != strings.Join(e.values, "|") {
+			t.Errorf("column %d excepted %v, actual %#v", idx, e, column)
+		}
+	}
+	if c := tables[0].Columns[3]; c.Precision != 10 || c.Scale != 2 {
+		t.Error("precision of d is", c.Precision, c.Scale)
+	}
+}
+
+func TestDDLComments(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(`CREATE TABLE tpt_books (
+  id serial PRIMARY KEY,
+  name varchar(100) NOT NULL COMMENT '书名',
+  isbn varchar(20)
+) ENGINE=InnoDB COMMENT='图书';
+COMMENT ON COLUMN public.tpt_books.isbn IS 'ISBN
+国际标准书号';`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := dbBase{dbPrefix: "tpt_"}
+	if err := cmd.initTable(&tables[0], tables[0].Columns); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := toClassSpec(tables[0])
+	if cs.Label != "图书" || len(cs.Fields) != 3 {
+		t.Fatal("class is", cs)
+	}
+	if cs.Fields[0].Type != "objectId" || cs.Fields[1].Label != "书名" || !cs.Fields[1].IsRequired ||
+		cs.Fields[2].Label != "ISBN" || cs.Fields[2].Description != "国际标准书号" {
+		t.Error("fields is", cs.Fields)
+	}
+}
+
+func TestDDLRestrictions(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(`CREATE TABLE tpt_books (
+  id serial PRIMARY KEY,
+  name varchar(100) NOT NULL DEFAULT 'unnamed'::character varying,
+  status varchar(10) DEFAULT 'draft' CHECK (status IN ('draft', 'published')),
+  pages int DEFAULT -1,
+  rating int,
+  created_at timestamp DEFAULT now(),
+  CONSTRAINT tpt_books_rating CHECK (rating BETWEEN 1 AND 5)
+);`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := dbBase{dbPrefix: "tpt_"}
+	if err := cmd.initTable(&tables[0], tables[0].Columns); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := toClassSpec(tables[0])
+	if len(cs.Fields) != 6 {
+		t.Fatal("fields is", cs.Fields)
+	}
+	name, status, pages, rating, createdAt := cs.Fields[1], cs.Fields[2], cs.Fields[3], cs.Fields[4], cs.Fields[5]
+	if name.DefaultValue != "unnamed" || name.Restrictions == nil || name.Restrictions.MaxLength != 100 {
+		t.Errorf("name is %#v", name)
+	}
+	if status.DefaultValue != "draft" || status.Restrictions == nil || len(status.Restrictions.Enumerations) != 2 ||
+		status.Restrictions.Enumerations[1].Value != "published" {
+		t.Errorf("status is %#v", status)
+	}
+	if pages.DefaultValue != "-1" || pages.Restrictions != nil {
+		t.Errorf("pages is %#v", pages)
+	}
+	if rating.Restrictions == nil || rating.Restrictions.MinValue != "1" || rating.Restrictions.MaxValue != "5" {
+		t.Errorf("rating is %#v", rating)
+	}
+	if createdAt.DefaultValue != "" {
+		t.Errorf("created_at is %#v", createdAt)
+	}
+
+	rules := parseCheck(`CHECK (((status)::text = ANY ((ARRAY['a'::character varying, 'b'::character varying])::text[])))`)
+	if rule := rules["status"]; rule == nil || len(rule.values) != 2 || rule.values[0] != "a" || rule.values[1] != "b" {
+		t.Errorf("rules is %#v", rules)
+	}
+	rules = parseCheck(`CHECK (((rating >= '-1'::integer) AND (rating <= 5)))`)
+	if rule := rules["rating"]; rule == nil || rule.minValue != "-1" || rule.maxValue != "5" {
+		t.Errorf("rules is %#v", rules)
+	}
+	if rules := parseCheck(`CHECK (((rating > 0) OR (rating IS NULL)))`); rules != nil {
+		t.Errorf("rules is %#v", rules)
+	}
+}
+
+func TestDDLInherits(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(`CREATE TABLE tpt_managed_objects (
+  id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  name varchar(100) NOT NULL
+);
+CREATE TABLE tpt_devices (
+  address inet NOT NULL
+) INHERITS (tpt_managed_objects);
+CREATE TABLE tpt_device_extensions (
+  device_key integer PRIMARY KEY REFERENCES tpt_devices (id),
+  vendor varchar(100)
+);`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := dbBase{dbPrefix: "tpt_"}
+	for idx := range tables {
+		if err := cmd.initTable(&tables[idx], tables[idx].Columns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	initRelations(tables)
+
+	devices := tables[1]
+	if len(devices.Columns) != 3 || devices.IsCombinedKey || len(devices.PrimaryKey) != 1 {
+		t.Fatal("devices is", devices)
+	}
+	if pk := devices.PrimaryKey[0]; pk.DbName != "id" || !pk.IsSequence || pk.GoType != "int64" {
+		t.Error("primary key of devices is", pk)
+	}
+
+	extensions := tables[2]
+	if len(extensions.PrimaryKey) != 1 || extensions.PrimaryKey[0].DbName != "device_key" ||
+		extensions.PrimaryKey[0].IsSequence || !extensions.PrimaryKey[0].IsForeignKey {
+		t.Error("primary key of extensions is", extensions.PrimaryKey)
+	}
+	if len(extensions.BelongsTo) != 1 || extensions.BelongsTo[0].Name != "Device" {
+		t.Error("relations of extensions is", extensions.BelongsTo)
+	}
+}
+
+func TestDDLForeignKeyTypes(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(`CREATE TABLE countries (
+  code char(2) PRIMARY KEY,
+  name varchar(100) NOT NULL
+);
+CREATE TABLE cities (
+  id serial PRIMARY KEY,
+  country_code char(2) REFERENCES countries(code),
+  capital_id integer REFERENCES cities(id)
+);`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := dbBase{}
+	for idx := range tables {
+		if err := cmd.initTable(&tables[idx], tables[idx].Columns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	initRelations(tables)
+
+	cities := tables[1]
+	for _, column := range cities.Columns {
+		switch column.DbName {
+		case "country_code":
+			if column.GoType != "string" {
+				t.Error("type of country_code is", column.GoType)
+			}
+		case "capital_id":
+			if column.GoType != "int64" {
+				t.Error("type of capital_id is", column.GoType)
+			}
+		}
+	}
+	for _, relation := range cities.BelongsTo {
+		if relation.Column.DbName == "country_code" && relation.Column.GoType != relation.TargetColumn.GoType {
+			t.Error("relation of country_code is", relation.Column.GoType, relation.TargetColumn.GoType)
+		}
+	}
+	if len(cities.BelongsTo) != 2 {
+		t.Error("relations of cities is", cities.BelongsTo)
+	}
+}
+
+func TestDDLUniqueAndDuplicateTables(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(`CREATE TABLE IF NOT EXISTS users (
+  id serial PRIMARY KEY,
+  email varchar(100) NOT NULL UNIQUE,
+  login varchar(100) CONSTRAINT uq_users_login UNIQUE,
+  name varchar(100),
+  CONSTRAINT uq_users_name UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS users (
+  id serial PRIMARY KEY
+);`); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || len(tables[0].Columns) != 4 {
+		t.Fatal("tables is", tables)
+	}
+
+	excepted := []Index{{Columns: []string{"email"}, IsUnique: true},
+		{Name: "uq_users_login", Columns: []string{"login"}, IsUnique: true},
+		{Name: "uq_users_name", Columns: []string{"name"}, IsUnique: true}}
+	if len(tables[0].Indexes) != len(excepted) {
+		t.Fatal("indexes is", tables[0].Indexes)
+	}
+	for idx, index := range tables[0].Indexes {
+		if index.Name != excepted[idx].Name || index.IsUnique != excepted[idx].IsUnique ||
+			strings.Join(index.Columns, ",") != strings.Join(excepted[idx].Columns, ",") {
+			t.Error("index is", index, "excepted", excepted[idx])
+		}
+	}
+
+	p = &ddlParser{}
+	if err := p.parse(`CREATE TABLE users (id serial PRIMARY KEY);
+CREATE TABLE users (id serial PRIMARY KEY);`); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Error("excepted duplicate error, actual", err)
+	}
+}
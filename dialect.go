@@ -0,0 +1,404 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// dialect - 读取数据库的表结构, 不同的数据库有不同的实现, 返回的列中 DbType 统一
+// 使用 PostgreSQL 的 udt_name, 如 int4, int8, varchar, timestamp 等, 这样模板中
+// 只需要处理一种类型名称
+type dialect interface {
+	// tables 返回所有的表和它们的列, 列不需要填写 GoName 和 GoType
+	tables(db *sql.DB) ([]Table, error)
+}
+
+// dialect 根据数据库驱动返回对应的 dialect
+func (cmd *dbBase) dialect() (dialect, error) {
+	switch cmd.dbDrv {
+	case "postgres", "pgx":
+		return &postgresDialect{catalog: cmd.dbCatalog, schema: cmd.dbSchema}, nil
+	case "mysql":
+		return &mysqlDialect{schema: cmd.dbSchema}, nil
+	case "sqlite3", "sqlite":
+		return &sqliteDialect{}, nil
+	default:
+		return nil, errors.New("db driver '" + cmd.dbDrv + "' is unsupported")
+	}
+}
+
+// tableSet 按表名查找表, 用于将分别读出的列和约束合并到表中
+type tableSet struct {
+	tables []Table
+	index  map[string]int
+}
+
+func (set *tableSet) add(table Table) {
+	if set.index == nil {
+		set.index = map[string]int{}
+	}
+	set.index[table.TableName] = len(set.tables)
+	set.tables = append(set.tables, table)
+}
+
+func (set *tableSet) get(name string) *Table {
+	if idx, ok := set.index[name]; ok {
+		return &set.tables[idx]
+	}
+	return nil
+}
+
+func (set *tableSet) column(tableName, columnName string) *Column {
+	table := set.get(tableName)
+	if table == nil {
+		return nil
+	}
+	for idx := range table.Columns {
+		if table.Columns[idx].DbName == columnName {
+			return &table.Columns[idx]
+		}
+	}
+	return nil
+}
+
+// postgresDialect - 从 PostgreSQL 的 information_schema 中读取表结构, 所有的表,
+// 列, 约束和约束中的列分别用一个查询读出, 然后在内存中合并
+type postgresDialect struct {
+	catalog string
+	schema  string
+}
+
+func (d *postgresDialect) tables(db *sql.DB) ([]Table, error) {
+	var set tableSet
+	if e := d.readTables(db, &set); nil != e {
+		return nil, errors.New("read tables fail, " + e.Error())
+	}
+	log.Println("[READ]", len(set.tables), "tables")
+
+	enums, e := d.readEnums(db)
+	if nil != e {
+		return nil, errors.New("read enums fail, " + e.Error())
+	}
+
+	count, e := d.readColumns(db, &set, enums)
+	if nil != e {
+		return nil, errors.New("read columns fail, " + e.Error())
+	}
+	log.Println("[READ]", count, "columns")
+
+	count, e = d.readConstraints(db, &set)
+	if nil != e {
+		return nil, errors.New("read constraints fail, " + e.Error())
+	}
+	log.Println("[READ]", count, "constraints")
+
+	count, e = d.readInherits(db, &set)
+	if nil != e {
+		return nil, errors.New("read inherits fail, " + e.Error())
+	}
+	log.Println("[READ]", count, "inherits")
+
+	count, e = d.readChecks(db, &set)
+	if nil != e {
+		return nil, errors.New("read checks fail, " + e.Error())
+	}
+	log.Println("[READ]", count, "checks")
+
+	count, e = d.readIndexes(db, &set)
+	if nil != e {
+		return nil, errors.New("read indexes fail, " + e.Error())
+	}
+	log.Println("[READ]", count, "indexes")
+	return set.tables, nil
+}
+
+func (d *postgresDialect) readTables(db *sql.DB, set *tableSet) error {
+	rows, e := db.Query(`SELECT table_name, table_schema, table_type,
+            obj_description((quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass, 'pg_class')
+        FROM information_schema.tables
+        WHERE table_catalog = $1 AND table_schema = $2
+        ORDER BY table_name`, d.catalog, d.schema)
+	if nil != e {
+		return e
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table Table
+		var tableType string
+		var comment sql.NullString
+		if e := rows.Scan(&table.TableName, &table.Schema, &tableType, &comment); nil != e {
+			return e
+		}
+		table.IsView = "view" == strings.ToLower(tableType)
+		table.Comment = comment.String
+		set.add(table)
+	}
+	return rows.Err()
+}
+
+// readEnums 读取所有的枚举类型, 键为 schema.name
+func (d *postgresDialect) readEnums(db *sql.DB) (map[string][]string, error) {
+	rows, e := db.Query(`SELECT n.nspname, t.typname, e.enumlabel
+        FROM pg_catalog.pg_type t
+        JOIN pg_catalog.pg_enum e ON e.enumtypid = t.oid
+        JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+        ORDER BY n.nspname, t.typname, e.enumsortorder`)
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	enums := map[string][]string{}
+	for rows.Next() {
+		var schema, name, label string
+		if e := rows.Scan(&schema, &name, &label); nil != e {
+			return nil, e
+		}
+		enums[schema+"."+name] = append(enums[schema+"."+name], label)
+	}
+	return enums, rows.Err()
+}
+
+func (d *postgresDialect) readColumns(db *sql.DB, set *tableSet, enums map[string][]string) (int, error) {
+	rows, e := db.Query(`SELECT table_name, column_name, is_nullable, data_type, udt_schema, udt_name,
+            (column_default IS NOT NULL AND column_default LIKE 'nextval%') OR is_identity = 'YES',
+            numeric_precision, numeric_scale, character_maximum_length, column_default,
+            col_description((quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass, ordinal_position)
+        FROM information_schema.columns
+        WHERE table_catalog = $1 AND table_schema = $2
+        ORDER BY table_name, ordinal_position`, d.catalog, d.schema)
+	if nil != e {
+		return 0, e
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var tableName, dataType, udtSchema string
+		var isNullable sql.NullString
+		var isSequence sql.NullBool
+		var precision, scale, maxLength sql.NullInt64
+		var defaultExpr, comment sql.NullString
+		var column Column
+		if e := rows.Scan(&tableName, &column.DbName, &isNullable, &dataType, &udtSchema, &column.DbType,
+			&isSequence, &precision, &scale, &maxLength, &defaultExpr, &comment); nil != e {
+			return 0, e
+		}
+
+		table := set.get(tableName)
+		if table == nil {
+			continue
+		}
+		column.IsNullable = isNullable.Valid && strings.ToLower(isNullable.String) == "yes"
+		column.IsSequence = isSequence.Valid && isSequence.Bool
+		column.Comment = comment.String
+		column.MaxLength = int(maxLength.Int64)
+		if defaultExpr.Valid && !column.IsSequence {
+			if tokens, e := tokenizeSQL(defaultExpr.String); nil == e {
+				column.DefaultValue = defaultValue(tokens)
+			}
+		}
+		if "numeric" == column.DbType {
+			column.Precision = int(precision.Int64)
+			column.Scale = int(scale.Int64)
+		}
+		if "USER-DEFINED" == dataType {
+			if values, ok := enums[udtSchema+"."+column.DbType]; ok {
+				column.DbType = "enum"
+				column.EnumValues = values
+			}
+		}
+		table.Columns = append(table.Columns, column)
+		count++
+	}
+	return count, rows.Err()
+}
+
+// readConstraints 读取主键和外键, 外键引用的列按 position_in_unique_constraint
+// 从被引用的唯一约束中找到
+func (d *postgresDialect) readConstraints(db *sql.DB, set *tableSet) (int, error) {
+	rows, e := db.Query(`SELECT tc.constraint_name, tc.constraint_type, kcu.table_name, kcu.column_name,
+            rk.table_name, rk.column_name
+        FROM information_schema.table_constraints tc
+        JOIN information_schema.key_column_usage kcu
+          ON kcu.constraint_catalog = tc.constraint_catalog
+          AND kcu.constraint_schema = tc.constraint_schema
+          AND kcu.constraint_name = tc.constraint_name
+          AND kcu.table_name = tc.table_name
+        LEFT JOIN information_schema.referential_constraints rc
+          ON rc.constraint_catalog = tc.constraint_catalog
+          AND rc.constraint_schema = tc.constraint_schema
+          AND rc.constraint_name = tc.constraint_name
+        LEFT JOIN information_schema.key_column_usage rk
+          ON rk.constraint_catalog = rc.unique_constraint_catalog
+          AND rk.constraint_schema = rc.unique_constraint_schema
+          AND rk.constraint_name = rc.unique_constraint_name
+          AND rk.ordinal_position = kcu.position_in_unique_constraint
+        WHERE tc.table_catalog = $1 AND tc.table_schema = $2
+          AND tc.constraint_type IN ('PRIMARY KEY', 'FOREIGN KEY')
+        ORDER BY kcu.table_name, tc.constraint_name, kcu.ordinal_position`, d.catalog, d.schema)
+	if nil != e {
+		return 0, e
+	}
+	defer rows.Close()
+
+	constraints := map[string]bool{}
+	for rows.Next() {
+		var constraintName, constraintType, tableName, columnName string
+		var refTable, refColumn sql.NullString
+		if e := rows.Scan(&constraintName, &constraintType, &tableName, &columnName, &refTable, &refColumn); nil != e {
+			return 0, e
+		}
+		constraints[tableName+"."+constraintName] = true
+
+		column := set.column(tableName, columnName)
+		if column == nil {
+			continue
+		}
+		switch constraintType {
+		case "PRIMARY KEY":
+			column.IsPrimaryKey = true
+		case "FOREIGN KEY":
+			column.IsForeignKey = true
+			column.RefTable = refTable.String
+			column.RefColumn = refColumn.String
+		}
+	}
+	return len(constraints), rows.Err()
+}
+
+// readInherits 读取表的继承关系, PostgreSQL 中子表会继承父表的列和缺省值, 但不会继承
+// 主键约束, 所以子表没有主键时使用父表的主键
+func (d *postgresDialect) readInherits(db *sql.DB, set *tableSet) (int, error) {
+	rows, e := db.Query(`SELECT c.relname, p.relname
+        FROM pg_catalog.pg_inherits i
+        JOIN pg_catalog.pg_class c ON c.oid = i.inhrelid
+        JOIN pg_catalog.pg_class p ON p.oid = i.inhparent
+        JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+        WHERE n.nspname = $1
+        ORDER BY c.relname, i.inhseqno`, d.schema)
+	if nil != e {
+		return 0, e
+	}
+	defer rows.Close()
+
+	parents := map[string]string{}
+	for rows.Next() {
+		var child, parent string
+		if e := rows.Scan(&child, &parent); nil != e {
+			return 0, e
+		}
+		if _, ok := parents[child]; !ok { // 多重继承时只使用第一个父表
+			parents[child] = parent
+		}
+	}
+	if e := rows.Err(); nil != e {
+		return 0, e
+	}
+
+	for child := range parents {
+		inheritPrimaryKey(set, parents, child, len(parents))
+	}
+	return len(parents), nil
+}
+
+// inheritPrimaryKey 子表没有主键时将父表主键中的列设为主键, 父表也没有主键时先从它的父表继承
+func inheritPrimaryKey(set *tableSet, parents map[string]string, tableName string, depth int) {
+	table := set.get(tableName)
+	parentName, ok := parents[tableName]
+	if table == nil || !ok || depth <= 0 {
+		return
+	}
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey {
+			return
+		}
+	}
+
+	inheritPrimaryKey(set, parents, parentName, depth-1)
+	parent := set.get(parentName)
+	if parent == nil {
+		return
+	}
+	for _, pk := range parent.Columns {
+		if !pk.IsPrimaryKey {
+			continue
+		}
+		if column := set.column(tableName, pk.DbName); column != nil {
+			column.IsPrimaryKey = true
+		}
+	}
+}
+
+// readChecks 读取 CHECK 约束, 只有 parseCheck 能识别的约束才会记录到列中
+func (d *postgresDialect) readChecks(db *sql.DB, set *tableSet) (int, error) {
+	rows, e := db.Query(`SELECT t.relname, pg_get_constraintdef(c.oid)
+        FROM pg_catalog.pg_constraint c
+        JOIN pg_catalog.pg_class t ON t.oid = c.conrelid
+        JOIN pg_catalog.pg_namespace n ON n.oid = t.relnamespace
+        WHERE n.nspname = $1 AND c.contype = 'c'
+        ORDER BY t.relname, c.conname`, d.schema)
+	if nil != e {
+		return 0, e
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var tableName, expr string
+		if e := rows.Scan(&tableName, &expr); nil != e {
+			return 0, e
+		}
+		for name, rule := range parseCheck(expr) {
+			if column := set.column(tableName, name); column != nil {
+				rule.apply(column)
+			}
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// readIndexes 从 pg_index 中读取除主键之外的索引, 唯一约束也有对应的索引,
+// 部分索引和有表达式的索引将被忽略
+func (d *postgresDialect) readIndexes(db *sql.DB, set *tableSet) (int, error) {
+	rows, e := db.Query(`SELECT t.relname, i.relname, ix.indisunique, a.attname
+        FROM pg_catalog.pg_index ix
+        JOIN pg_catalog.pg_class t ON t.oid = ix.indrelid
+        JOIN pg_catalog.pg_class i ON i.oid = ix.indexrelid
+        JOIN pg_catalog.pg_namespace n ON n.oid = t.relnamespace
+        CROSS JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+        JOIN pg_catalog.pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+        WHERE n.nspname = $1 AND NOT ix.indisprimary
+          AND ix.indpred IS NULL AND ix.indexprs IS NULL
+        ORDER BY t.relname, i.relname, k.ord`, d.schema)
+	if nil != e {
+		return 0, e
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var tableName, indexName, columnName string
+		var isUnique bool
+		if e := rows.Scan(&tableName, &indexName, &isUnique, &columnName); nil != e {
+			return 0, e
+		}
+		table := set.get(tableName)
+		if table == nil {
+			continue
+		}
+		if n := len(table.Indexes); n > 0 && table.Indexes[n-1].Name == indexName {
+			table.Indexes[n-1].Columns = append(table.Indexes[n-1].Columns, columnName)
+		} else {
+			table.Indexes = append(table.Indexes, Index{Name: indexName, Columns: []string{columnName}, IsUnique: isUnique})
+			count++
+		}
+	}
+	return count, rows.Err()
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// mysqlDialect - 从 MySQL 的 information_schema 中读取表结构, schema 为数据库名,
+// 为空或为 public 时使用当前连接的数据库
+type mysqlDialect struct {
+	schema string
+}
+
+func (d *mysqlDialect) schemaExpr() (string, []interface{}) {
+	if d.schema == "" || d.schema == "public" {
+		return "DATABASE()", nil
+	}
+	return "?", []interface{}{d.schema}
+}
+
+func (d *mysqlDialect) tables(db *sql.DB) ([]Table, error) {
+	expr, args := d.schemaExpr()
+	rows, e := db.Query(`SELECT table_name, table_schema, table_type, table_comment
+        FROM information_schema.tables
+        WHERE table_schema = `+expr+`
+        ORDER BY table_name`, args...)
+	if nil != e {
+		return nil, errors.New("read tables fail, " + e.Error())
+	}
+	defer rows.Close()
+
+	var set tableSet
+	for rows.Next() {
+		var table Table
+		var tableType string
+		if e := rows.Scan(&table.TableName, &table.Schema, &tableType, &table.Comment); nil != e {
+			return nil, e
+		}
+		table.IsView = "view" == strings.ToLower(tableType)
+		if table.IsView && "VIEW" == table.Comment {
+			table.Comment = ""
+		}
+		set.add(table)
+	}
+	if e := rows.Err(); nil != e {
+		return nil, e
+	}
+	log.Println("[READ]", len(set.tables), "tables")
+
+	records, e := d.readColumns(db)
+	if nil != e {
+		return nil, errors.New("read columns fail, " + e.Error())
+	}
+	log.Println("[READ]", len(records), "columns")
+
+	byTable := map[string][]mysqlColumn{}
+	for _, record := range records {
+		byTable[record.TableName] = append(byTable[record.TableName], record)
+	}
+	for idx := range set.tables {
+		table := &set.tables[idx]
+		table.Columns, e = toMysqlColumns(table.TableName, byTable[table.TableName])
+		if nil != e {
+			return nil, e
+		}
+	}
+
+	count, e := d.readIndexes(db, &set)
+	if nil != e {
+		return nil, errors.New("read indexes fail, " + e.Error())
+	}
+	log.Println("[READ]", count, "indexes")
+	return set.tables, nil
+}
+
+// readIndexes 从 information_schema.statistics 中读取除主键之外的索引
+func (d *mysqlDialect) readIndexes(db *sql.DB, set *tableSet) (int, error) {
+	expr, args := d.schemaExpr()
+	rows, e := db.Query(`SELECT table_name, index_name, non_unique, column_name
+        FROM information_schema.statistics
+        WHERE table_schema = `+expr+` AND index_name <> 'PRIMARY' AND column_name IS NOT NULL
+        ORDER BY table_name, index_name, seq_in_index`, args...)
+	if nil != e {
+		return 0, e
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var tableName, indexName, columnName string
+		var nonUnique int
+		if e := rows.Scan(&tableName, &indexName, &nonUnique, &columnName); nil != e {
+			return 0, e
+		}
+		table := set.get(tableName)
+		if table == nil {
+			continue
+		}
+		if n := len(table.Indexes); n > 0 && table.Indexes[n-1].Name == indexName {
+			table.Indexes[n-1].Columns = append(table.Indexes[n-1].Columns, columnName)
+		} else {
+			table.Indexes = append(table.Indexes, Index{Name: indexName, Columns: []string{columnName}, IsUnique: nonUnique == 0})
+			count++
+		}
+	}
+	return count, rows.Err()
+}
+
+// mysqlColumn 为 information_schema.columns 中的一行
+type mysqlColumn struct {
+	TableName    string
+	Name         string
+	IsNullable   string
+	DataType     string // 如 int, varchar
+	ColumnType   string // 如 int(10) unsigned, tinyint(1)
+	ColumnKey    string // PRI, UNI 或 MUL
+	Extra        string // 如 auto_increment
+	IsForeignKey bool
+	RefTable     string
+	RefColumn    string
+	Comment      string
+	Default      sql.NullString
+}
+
+// readColumns 读取所有表的列, 外键和它引用的列由 key_column_usage 中的 referenced_table_name
+// 和 referenced_column_name 判断
+func (d *mysqlDialect) readColumns(db *sql.DB) ([]mysqlColumn, error) {
+	expr, args := d.schemaExpr()
+	rows, e := db.Query(`SELECT c.table_name, c.column_name, c.is_nullable, c.data_type, c.column_type,
+            c.column_key, c.extra, fk.column_name IS NOT NULL,
+            COALESCE(fk.ref_table, ''), COALESCE(fk.ref_column, ''), c.column_comment,
+            c.column_default
+        FROM information_schema.columns c
+        LEFT JOIN (SELECT table_schema, table_name, column_name,
+                MIN(referenced_table_name) AS ref_table, MIN(referenced_column_name) AS ref_column
+            FROM information_schema.key_column_usage
+            WHERE referenced_table_name IS NOT NULL
+            GROUP BY table_schema, table_name, column_name) fk
+          ON fk.table_schema = c.table_schema
+          AND fk.table_name = c.table_name
+          AND fk.column_name = c.column_name
+        WHERE c.table_schema = `+expr+`
+        ORDER BY c.table_name, c.ordinal_position`, args...)
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var records []mysqlColumn
+	for rows.Next() {
+		var record mysqlColumn
+		if e := rows.Scan(&record.TableName,
+			&record.Name,
+			&record.IsNullable,
+			&record.DataType,
+			&record.ColumnType,
+			&record.ColumnKey,
+			&record.Extra,
+			&record.IsForeignKey,
+			&record.RefTable,
+			&record.RefColumn,
+			&record.Comment,
+			&record.Default); nil != e {
+			return nil, e
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// toMysqlColumns 将 information_schema.columns 中的记录转换为 Column
+func toMysqlColumns(tableName string, records []mysqlColumn) ([]Column, error) {
+	columns := make([]Column, 0, len(records))
+	for _, record := range records {
+		dbType, e := toDbTypeFromMysql(record.DataType, record.ColumnType)
+		if nil != e {
+			return nil, errors.New("column '" + record.Name + "' of '" + tableName + "' is invalid, " + e.Error())
+		}
+		precision, scale, values := typeArgs(dbType, record.ColumnType)
+		columns = append(columns, Column{
+			DbName:       record.Name,
+			DbType:       dbType,
+			IsNullable:   strings.ToLower(record.IsNullable) == "yes",
+			IsPrimaryKey: record.ColumnKey == "PRI",
+			IsForeignKey: record.IsForeignKey,
+			IsSequence:   strings.Contains(strings.ToLower(record.Extra), "auto_increment"),
+			RefTable:     record.RefTable,
+			RefColumn:    record.RefColumn,
+			Precision:    precision,
+			Scale:        scale,
+			EnumValues:   values,
+			MaxLength:    typeLength(dbType, record.ColumnType),
+			DefaultValue: toMysqlDefault(record),
+			Comment:      record.Comment,
+		})
+	}
+	return columns, nil
+}
+
+// toMysqlDefault 返回列的缺省值, MySQL 中字符串类型的缺省值没有引号, 而 8.0 之后
+// 表达式的缺省值在 extra 中有 DEFAULT_GENERATED
+func toMysqlDefault(record mysqlColumn) string {
+	if !record.Default.Valid || strings.Contains(strings.ToUpper(record.Extra), "DEFAULT_GENERATED") {
+		return ""
+	}
+	value := record.Default.String
+	if strings.HasPrefix(strings.ToUpper(value), "CURRENT_TIMESTAMP") || strings.EqualFold(value, "NULL") {
+		return ""
+	}
+	return value
+}
+
+// toDbTypeFromMysql 将 MySQL 的类型转换为对应的 PostgreSQL 的 udt_name
+func toDbTypeFromMysql(dataType, columnType string) (string, error) {
+	columnType = strings.ToLower(columnType)
+	unsigned := strings.Contains(columnType, "unsigned")
+
+	switch strings.ToLower(dataType) {
+	case "tinyint":
+		if strings.HasPrefix(columnType, "tinyint(1)") {
+			return "bool", nil
+		}
+		return "int4", nil
+	case "bit":
+		if columnType == "bit(1)" {
+			return "bool", nil
+		}
+		return "int8", nil
+	case "smallint":
+		if unsigned {
+			return "int4", nil
+		}
+		return "int2", nil
+	case "mediumint":
+		return "int4", nil
+	case "int", "integer":
+		if unsigned {
+			return "int8", nil
+		}
+		return "int4", nil
+	case "bigint":
+		return "int8", nil
+	case "float":
+		return "float4", nil
+	case "double", "real":
+		return "float8", nil
+	case "decimal", "numeric":
+		return "numeric", nil
+	case "varchar", "set":
+		return "varchar", nil
+	case "char":
+		return "bpchar", nil
+	case "enum":
+		return "enum", nil
+	case "tinytext", "text", "mediumtext", "longtext":
+		return "text", nil
+	case "datetime", "timestamp":
+		return "timestamp", nil
+	case "date":
+		return "date", nil
+	case "time":
+		// MySQL 的 time 是一个时间段, 可以为负数或超过 24 小时
+		return "interval", nil
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "bytea", nil
+	case "json":
+		return "json", nil
+	default:
+		return "", errors.New("type '" + columnType + "' is unsupported")
+	}
+}
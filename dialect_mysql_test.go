@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+// 从 MySQL 5.7 中记录的 information_schema.columns
+var mysqlBooksColumns = []mysqlColumn{
+	{Name: "id", IsNullable: "NO", DataType: "int", ColumnType: "int(11)", ColumnKey: "PRI", Extra: "auto_increment"},
+	{Name: "created_at", IsNullable: "YES", DataType: "datetime", ColumnType: "datetime"},
+	{Name: "author_id", IsNullable: "YES", DataType: "bigint", ColumnType: "bigint(20)", ColumnKey: "MUL", IsForeignKey: true},
+	{Name: "name", IsNullable: "NO", DataType: "varchar", ColumnType: "varchar(100)", ColumnKey: "UNI"},
+	{Name: "pages", IsNullable: "YES", DataType: "int", ColumnType: "int(10) unsigned"},
+	{Name: "price", IsNullable: "YES", DataType: "decimal", ColumnType: "decimal(10,2)"},
+	{Name: "published", IsNullable: "NO", DataType: "tinyint", ColumnType: "tinyint(1)"},
+	{Name: "summary", IsNullable: "YES", DataType: "longtext", ColumnType: "longtext"},
+	{Name: "attributes", IsNullable: "YES", DataType: "json", ColumnType: "json"},
+}
+
+func TestMysqlColumns(t *testing.T) {
+	columns, err := toMysqlColumns("tpt_books", mysqlBooksColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cmd dbBase
+	cmd.dbPrefix = "tpt_"
+	table := Table{TableName: "tpt_books"}
+	if err := cmd.initTable(&table, columns); err != nil {
+		t.Fatal(err)
+	}
+
+	if table.ClassName != "Book" {
+		t.Error("ClassName is", table.ClassName)
+	}
+	if table.IsCombinedKey || len(table.PrimaryKey) != 1 || !table.PrimaryKey[0].IsSequence {
+		t.Error("primary key is", table.PrimaryKey)
+	}
+	if !table.HasCreatedAt || table.HasUpdatedAt {
+		t.Error("HasCreatedAt/HasUpdatedAt is", table.HasCreatedAt, table.HasUpdatedAt)
+	}
+
+	excepted := []struct {
+		name, dbType, goType string
+		nullable             bool
+	}{
+		{"id", "int4", "int64", false},
+		{"name", "varchar", "string", false},
+		{"author_id", "int8", "int64", true},
+		{"pages", "int8", "int64", true},
+		{"price", "numeric", "float64", true},
+		{"published", "bool", "bool", false},
+		{"summary", "text", "string", true},
+		{"attributes", "json", "JSON", true},
+		{"created_at", "timestamp", "time.Time", true},
+	}
+	if len(table.Columns) != len(excepted) {
+		t.Fatal("columns is", table.Columns)
+	}
+	for idx, column := range table.Columns {
+		e := excepted[idx]
+		if column.DbName != e.name || column.DbType != e.dbType ||
+			column.GoType != e.goType || column.IsNullable != e.nullable {
+			t.Errorf("column %d excepted %v, actual %#v", idx, e, column)
+		}
+	}
+
+	if _, err := toMysqlColumns("tpt_books", []mysqlColumn{{Name: "geo", DataType: "geometry", ColumnType: "geometry"}}); err == nil {
+		t.Error("excepted error")
+	}
+}
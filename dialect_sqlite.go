@@ -0,0 +1,278 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDialect - 用 PRAGMA table_info, foreign_key_list 和 index_list 读取 SQLite 的表结构
+type sqliteDialect struct{}
+
+func quoteSqliteName(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (d *sqliteDialect) tables(db *sql.DB) ([]Table, error) {
+	rows, e := db.Query(`SELECT name, type FROM sqlite_master
+        WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+        ORDER BY name`)
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var tables []Table
+	for rows.Next() {
+		var table Table
+		var tableType string
+		if e := rows.Scan(&table.TableName, &tableType); nil != e {
+			return nil, e
+		}
+		table.Schema = "main"
+		table.IsView = "view" == tableType
+		tables = append(tables, table)
+	}
+	if e := rows.Err(); nil != e {
+		return nil, e
+	}
+	rows.Close()
+	log.Println("[READ]", len(tables), "tables")
+
+	for idx := range tables {
+		tables[idx].Columns, e = d.columns(db, tables[idx].TableName)
+		if nil != e {
+			return nil, errors.New("read columns of '" + tables[idx].TableName + "' fail, " + e.Error())
+		}
+		tables[idx].Indexes, e = d.indexes(db, tables[idx].TableName)
+		if nil != e {
+			return nil, errors.New("read indexes of '" + tables[idx].TableName + "' fail, " + e.Error())
+		}
+		if (idx+1)%100 == 0 {
+			log.Println("[READ] columns of", idx+1, "tables")
+		}
+	}
+	return tables, nil
+}
+
+func (d *sqliteDialect) columns(db *sql.DB, tableName string) ([]Column, error) {
+	foreignKeys, e := d.foreignKeys(db, tableName)
+	if nil != e {
+		return nil, e
+	}
+	hasPkIndex, e := d.hasPrimaryKeyIndex(db, tableName)
+	if nil != e {
+		return nil, e
+	}
+
+	rows, e := db.Query("PRAGMA table_info(" + quoteSqliteName(tableName) + ")")
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var columns []Column
+	var pkCount int
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var defaultExpr sql.NullString
+		if e := rows.Scan(&cid, &name, &typ, &notNull, &defaultExpr, &pk); nil != e {
+			return nil, e
+		}
+
+		dbType, e := toDbTypeFromSqlite(typ)
+		if nil != e {
+			return nil, errors.New("column '" + name + "' of '" + tableName + "' is invalid, " + e.Error())
+		}
+		if pk > 0 {
+			pkCount++
+		}
+		precision, scale, _ := typeArgs(dbType, typ)
+		var defaultTokens []sqlToken
+		if defaultExpr.Valid {
+			defaultTokens, _ = tokenizeSQL(defaultExpr.String)
+		}
+		columns = append(columns, Column{
+			DbName:       name,
+			DbType:       dbType,
+			IsNullable:   notNull == 0 && pk == 0,
+			IsPrimaryKey: pk > 0,
+			IsForeignKey: foreignKeys[name].table != "",
+			RefTable:     foreignKeys[name].table,
+			RefColumn:    foreignKeys[name].column,
+			// 只有一个 INTEGER 类型的主键, 并且主键没有单独的索引时它是 rowid 的别名, 会自动增长
+			IsSequence: pk > 0 && strings.ToUpper(typ) == "INTEGER",
+			Precision:  precision,
+			Scale:      scale,
+			MaxLength:  typeLength(dbType, typ),
+
+			DefaultValue: defaultValue(defaultTokens),
+		})
+	}
+	if e := rows.Err(); nil != e {
+		return nil, e
+	}
+
+	if pkCount != 1 || hasPkIndex {
+		for idx := range columns {
+			columns[idx].IsSequence = false
+		}
+	}
+	return columns, nil
+}
+
+// sqliteForeignKey 外键引用的表和列, column 为空时引用的是表的主键
+type sqliteForeignKey struct {
+	table  string
+	column string
+}
+
+// foreignKeys 返回表中所有的外键列
+func (d *sqliteDialect) foreignKeys(db *sql.DB, tableName string) (map[string]sqliteForeignKey, error) {
+	rows, e := db.Query("PRAGMA foreign_key_list(" + quoteSqliteName(tableName) + ")")
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	foreignKeys := map[string]sqliteForeignKey{}
+	for rows.Next() {
+		var id, seq int
+		var table, from string
+		var to, onUpdate, onDelete, match sql.NullString
+		if e := rows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); nil != e {
+			return nil, e
+		}
+		foreignKeys[from] = sqliteForeignKey{table: table, column: to.String}
+	}
+	return foreignKeys, rows.Err()
+}
+
+// hasPrimaryKeyIndex 判断主键是否有单独的索引, 有时表示主键不是 rowid 的别名
+func (d *sqliteDialect) hasPrimaryKeyIndex(db *sql.DB, tableName string) (bool, error) {
+	rows, e := db.Query("PRAGMA index_list(" + quoteSqliteName(tableName) + ")")
+	if nil != e {
+		return false, e
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if e := rows.Scan(&seq, &name, &unique, &origin, &partial); nil != e {
+			return false, e
+		}
+		if "pk" == origin {
+			found = true
+		}
+	}
+	return found, rows.Err()
+}
+
+// indexes 返回表中除主键之外的索引, 部分索引和有表达式的索引将被忽略
+func (d *sqliteDialect) indexes(db *sql.DB, tableName string) ([]Index, error) {
+	rows, e := db.Query("PRAGMA index_list(" + quoteSqliteName(tableName) + ")")
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var indexes []Index
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if e := rows.Scan(&seq, &name, &unique, &origin, &partial); nil != e {
+			return nil, e
+		}
+		if "pk" == origin || partial != 0 {
+			continue
+		}
+		indexes = append(indexes, Index{Name: name, IsUnique: unique != 0})
+	}
+	if e := rows.Err(); nil != e {
+		return nil, e
+	}
+	rows.Close()
+
+	var results []Index
+	for _, index := range indexes {
+		columns, e := d.indexColumns(db, index.Name)
+		if nil != e {
+			return nil, e
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		index.Columns = columns
+		results = append(results, index)
+	}
+	return results, nil
+}
+
+// indexColumns 返回索引中的列, 索引中有表达式时返回 nil
+func (d *sqliteDialect) indexColumns(db *sql.DB, indexName string) ([]string, error) {
+	rows, e := db.Query("PRAGMA index_info(" + quoteSqliteName(indexName) + ")")
+	if nil != e {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var columns []string
+	hasExpr := false
+	for rows.Next() {
+		var seqno, cid int
+		var name sql.NullString
+		if e := rows.Scan(&seqno, &cid, &name); nil != e {
+			return nil, e
+		}
+		if !name.Valid {
+			hasExpr = true
+			continue
+		}
+		columns = append(columns, name.String)
+	}
+	if hasExpr {
+		return nil, rows.Err()
+	}
+	return columns, rows.Err()
+}
+
+// toDbTypeFromSqlite 按 SQLite 的类型亲和性规则将声明的类型转换为对应的 PostgreSQL 的 udt_name
+func toDbTypeFromSqlite(typ string) (string, error) {
+	typ = strings.ToUpper(typ)
+	if idx := strings.Index(typ, "("); idx >= 0 {
+		typ = strings.TrimSpace(typ[:idx])
+	}
+
+	switch {
+	case typ == "BOOL" || typ == "BOOLEAN":
+		return "bool", nil
+	case typ == "DATE":
+		return "date", nil
+	case strings.Contains(typ, "DATE") || strings.Contains(typ, "TIME"):
+		return "timestamp", nil
+	case typ == "UUID":
+		return "uuid", nil
+	case strings.Contains(typ, "BLOB") || typ == "":
+		return "bytea", nil
+	case typ == "JSON":
+		return "json", nil
+	case strings.Contains(typ, "INT"):
+		return "int8", nil
+	case strings.Contains(typ, "CHAR"):
+		return "varchar", nil
+	case strings.Contains(typ, "CLOB") || strings.Contains(typ, "TEXT"):
+		return "text", nil
+	case strings.Contains(typ, "REAL") || strings.Contains(typ, "FLOA") || strings.Contains(typ, "DOUB"):
+		return "float8", nil
+	case strings.Contains(typ, "NUMERIC") || strings.Contains(typ, "DECIMAL"):
+		return "numeric", nil
+	default:
+		return "", errors.New("type '" + typ + "' is unsupported")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSqliteColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if isPlaceholderWithDollar(db) || isPostgersql(db) {
+		t.Error("sqlite uses ? placeholders")
+	}
+
+	for _, s := range []string{
+		`CREATE TABLE tpt_authors (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL)`,
+		`CREATE TABLE tpt_books (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(100) NOT NULL,
+			author_id INTEGER REFERENCES tpt_authors(id),
+			price DECIMAL(10, 2),
+			published BOOLEAN NOT NULL,
+			created_at DATETIME)`,
+		`CREATE TABLE tpt_tags (book_id INTEGER, tag TEXT, PRIMARY KEY(book_id, tag))`,
+		`CREATE UNIQUE INDEX uq_books_name ON tpt_books (name, author_id)`,
+		`CREATE INDEX ix_books_author ON tpt_books (author_id)`,
+		`CREATE INDEX ix_books_lower_name ON tpt_books (lower(name))`,
+		`CREATE VIEW tpt_book_names AS SELECT name FROM tpt_books`,
+	} {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var d sqliteDialect
+	tables, err := d.tables(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 4 || tables[1].TableName != "tpt_book_names" || !tables[1].IsView {
+		t.Fatal("tables is", tables)
+	}
+
+	cmd := dbBase{dbPrefix: "tpt_"}
+	for idx := range tables {
+		if err := cmd.initTable(&tables[idx], tables[idx].Columns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	books := tables[2]
+	if books.IsCombinedKey || len(books.PrimaryKey) != 1 || !books.PrimaryKey[0].IsSequence {
+		t.Error("primary key of books is", books.PrimaryKey)
+	}
+	excepted := []struct {
+		name, dbType, goType string
+		nullable, foreignKey bool
+	}{
+		{"id", "int8", "int64", false, false},
+		{"name", "varchar", "string", false, false},
+		{"author_id", "int8", "int64", true, true},
+		{"price", "numeric", "float64", true, false},
+		{"published", "bool", "bool", false, false},
+		{"created_at", "timestamp", "time.Time", true, false},
+	}
+	if len(books.Columns) != len(excepted) {
+		t.Fatal("columns is", books.Columns)
+	}
+	for idx, column := range books.Columns {
+		e := excepted[idx]
+		if column.DbName != e.name || column.DbType != e.dbType || column.GoType != e.goType ||
+			column.IsNullable != e.nullable || column.IsForeignKey != e.foreignKey {
+			t.Errorf("column %d excepted %v, actual %#v", idx, e, column)
+		}
+	}
+
+	tags := tables[3]
+	if !tags.IsCombinedKey || len(tags.PrimaryKey) != 2 || tags.PrimaryKey[0].IsSequence {
+		t.Error("primary key of tags is", tags.PrimaryKey)
+	}
+	if len(tags.Indexes) != 0 {
+		t.Error("indexes of tags is", tags.Indexes)
+	}
+
+	finders := toFinders(books)
+	if len(finders) != 2 ||
+		finders[0].Name != "ListByAuthorId" || finders[0].IsUnique ||
+		finders[1].Name != "FindByNameAndAuthorId" || !finders[1].IsUnique ||
+		finders[1].Params[1] != "authorId" {
+		t.Errorf("finders is %#v", finders)
+	}
+}
@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+func controllerContext(gen *Generator, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{}) {
+	funcs := template.FuncMap{"displayForBelongsTo": func(f types.FieldSpec) string {
+		ann := f.Annotations["display"]
+		if s, ok := ann.(string); ok {
+			return types.Goify(s, true)
+		}
+
+		fields := ReferenceFields(f)
+		if len(fields) >= 1 {
+			return types.Goify(fields[0].Name, true)
+		}
+
+		return "Name"
+	}}
+
+	params := map[string]interface{}{"namespace": gen.Namespace,
+		"baseController": gen.BaseController,
+		"projectPath":    gen.ProjectPath,
+		"classes":        classes}
+	if cls != nil {
+		params["controllerName"] = types.Pluralize(cls.Name)
+		params["modelName"] = types.Pluralize(cls.Name)
+		params["class"] = cls
+	}
+	return funcs, params
+}
+
+// Controller 生成类的控制器
+func (gen *Generator) Controller(cls *types.ClassSpec) error {
+	return gen.Generate("controller", cls)
+}
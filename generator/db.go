@@ -0,0 +1,13 @@
+package generator
+
+import "github.com/three-plus-three/gengen/types"
+
+// DB 生成所有类的数据库访问对象
+func (gen *Generator) DB(classes []*types.ClassSpec) error {
+	return gen.Project("db", classes)
+}
+
+// TestBase 生成所有测试的基类
+func (gen *Generator) TestBase(classes []*types.ClassSpec) error {
+	return gen.Project("test_base", classes)
+}
@@ -0,0 +1,274 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+func (gen *Generator) funcs() template.FuncMap {
+	return template.FuncMap{
+		"set": func(ctx map[string]interface{}, name string, value interface{}) string {
+			ctx[name] = value
+			return ""
+		},
+		"goify":             types.Goify,
+		"gotype":            types.GoTypename,
+		"underscore":        types.Underscore,
+		"tableize":          types.Tableize,
+		"singularize":       types.Singularize,
+		"pluralize":         types.Pluralize,
+		"camelizeDownFirst": types.CamelizeDownFirst,
+		"toFormat":          toFormatFunc,
+		"omitempty": func(t *types.FieldSpec) bool {
+			return !t.IsRequired
+		},
+		"editDisabled": func(f interface{}) bool {
+			return HasFeature(f, "editDisabled")
+		},
+		"newDisabled": func(f interface{}) bool {
+			return HasFeature(f, "newDisabled")
+		},
+		"deleteDisabled": func(f interface{}) bool {
+			return HasFeature(f, "deleteDisabled")
+		},
+		"variable": func(name string) interface{} {
+			return gen.Variables[name]
+		},
+		"variables": func() map[string]interface{} {
+			return gen.Variables
+		},
+		"class":              gen.Class,
+		"referenceFields":    ReferenceFields,
+		"valueInAnnotations": ValueInAnnotations,
+		"hasFeature":         HasFeature,
+		"hasAnyFeatures": func(f interface{}, names ...string) bool {
+			for _, nm := range names {
+				if HasFeature(f, nm) {
+					return true
+				}
+			}
+			return false
+		},
+		"hasAllFeatures": func(f interface{}, names ...string) bool {
+			for _, nm := range names {
+				if !HasFeature(f, nm) {
+					return false
+				}
+			}
+			return true
+		},
+		"fieldExists": func(cls *types.ClassSpec, fieldName string) bool {
+			for _, field := range cls.Fields {
+				if field.Name == fieldName {
+					return true
+				}
+			}
+			return false
+		},
+		"field": func(cls *types.ClassSpec, fieldName string) types.FieldSpec {
+			for _, field := range cls.Fields {
+				if field.Name == fieldName {
+					return field
+				}
+			}
+			log.Fatalln(errors.New("field '" + fieldName + "' isn't exists in the " + cls.Name))
+			os.Exit(-1)
+			return types.FieldSpec{}
+		},
+		"hasEnumerations": func(f types.FieldSpec) bool {
+			if f.Restrictions == nil {
+				return false
+			}
+			return len(f.Restrictions.Enumerations) > 0
+		},
+		"isBelongsTo": func(cls *types.ClassSpec, f types.FieldSpec) bool {
+			for _, belongsTo := range cls.BelongsTo {
+				if belongsTo.Name == f.Name {
+					return true
+				}
+			}
+			return false
+		},
+		"belongsTo": func(cls *types.ClassSpec, f types.FieldSpec) *types.BelongsTo {
+			for _, belongsTo := range cls.BelongsTo {
+				if belongsTo.Name == f.Name {
+					return &belongsTo
+				}
+			}
+			return nil
+		}}
+}
+
+type ReferenceField struct {
+	Name  string
+	Label string
+}
+
+func ReferenceFields(f types.FieldSpec) []ReferenceField {
+	if f.Annotations == nil {
+		return nil
+	}
+
+	a := f.Annotations["referenceFields"]
+	if a == nil {
+		return nil
+	}
+	var names []ReferenceField
+	switch values := a.(type) {
+	case []string:
+		for _, s := range values {
+			names = append(names, ReferenceField{Name: s})
+		}
+	case []interface{}:
+		names = make([]ReferenceField, 0, len(values))
+		for _, v := range values {
+			switch value := v.(type) {
+			case string:
+				names = append(names, ReferenceField{Name: value})
+			case map[string]interface{}:
+				field := ReferenceField{Name: fmt.Sprint(value["name"])}
+				if label := value["label"]; label != nil {
+					field.Label = fmt.Sprint(label)
+				}
+				names = append(names, field)
+			case map[interface{}]interface{}:
+				field := ReferenceField{Name: fmt.Sprint(value["name"])}
+				for k, vv := range value {
+					switch fmt.Sprint(k) {
+					case "name":
+						field.Name = fmt.Sprint(vv)
+					case "label":
+						field.Label = fmt.Sprint(vv)
+					}
+				}
+				names = append(names, field)
+			default:
+				fmt.Printf("%T %v\r\n", v, v)
+				panic(errors.New("referenceFields of '" + f.Name + "' isn't string array or object array"))
+			}
+		}
+	default:
+		fmt.Printf("%T %v\r\n", a, a)
+		panic(errors.New("referenceFields of '" + f.Name + "' isn't string array or object array"))
+	}
+
+	return names
+}
+
+func toFormatFunc(f types.FieldSpec) string {
+	if f.Annotations != nil {
+		if format := f.Annotations["columnFormat"]; format != nil {
+			return fmt.Sprint(format)
+		}
+
+		if format := f.Annotations["enumerationSource"]; format != nil {
+			return fmt.Sprint(format) + "_format"
+		}
+	}
+
+	if f.Restrictions != nil && len(f.Restrictions.Enumerations) > 0 {
+		return f.Name + "_format"
+	}
+	switch f.Format {
+	case "net.IP", "ip", "mac", "email":
+		return ""
+	case "":
+		return ""
+	default:
+		return f.Format
+	}
+}
+
+func localizeName(t interface{}) string {
+	switch f := t.(type) {
+	case types.FieldSpec:
+		if f.Label != "" {
+			return f.Label
+		}
+		return f.Name
+	case *types.FieldSpec:
+		if f.Label != "" {
+			return f.Label
+		}
+		return f.Name
+	case *types.ClassSpec:
+		if f.Label != "" {
+			return f.Label
+		}
+		return f.Name
+	default:
+		panic(fmt.Errorf("arguments of localizeName is unknown(%T: %v)", t, t))
+	}
+}
+
+func HasFeature(f interface{}, name string) bool {
+	var annotations map[string]interface{}
+	switch v := f.(type) {
+	case types.FieldSpec:
+		annotations = v.Annotations
+	case *types.FieldSpec:
+		annotations = v.Annotations
+	case *types.ClassSpec:
+		annotations = v.Annotations
+	case types.ClassSpec:
+		annotations = v.Annotations
+	default:
+		panic(fmt.Errorf("unknown type - %T - %v", f, f))
+	}
+
+	for k, ann := range annotations {
+		if k == name {
+			if v := strings.ToLower(fmt.Sprint(ann)); v == "true" || v == "yes" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ValueInAnnotations(f interface{}, name string) interface{} {
+	var annotations map[string]interface{}
+	switch v := f.(type) {
+	case types.FieldSpec:
+		annotations = v.Annotations
+	case *types.FieldSpec:
+		annotations = v.Annotations
+	case *types.ClassSpec:
+		annotations = v.Annotations
+	case types.ClassSpec:
+		annotations = v.Annotations
+	default:
+		panic(fmt.Errorf("unknown type - %T - %v", f, f))
+	}
+
+	for k, ann := range annotations {
+		if k == name {
+			return ann
+		}
+	}
+	return nil
+}
+
+func getTableName(t *types.ClassSpec) string {
+	if t.Table != "" {
+		return t.Table
+	}
+	return types.Tableize(t.Name)
+}
+
+func omitempty(t types.FieldSpec) bool {
+	return !t.IsRequired
+}
+
+func isID(f types.FieldSpec) bool {
+	if f.Name == "id" {
+		return true
+	}
+	return false
+}
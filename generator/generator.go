@@ -0,0 +1,186 @@
+// Package generator 从类的规格说明 (types.ClassSpec) 生成代码, gengen 的命令行
+// 工具就是在它之上构建的, 其它工具也可以直接用它来生成代码。
+//
+//	classes, err := generator.LoadSpecs("specs")
+//	...
+//	sink := generator.NewMemorySink()
+//	gen := generator.New(generator.Options{Namespace: "models"}, classes, sink)
+//	err = gen.Struct(classes[0])
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+// Options - 生成器的选项
+type Options struct {
+	Namespace string // 生成的 go 代码的包名
+	Root      string // 模板的根目录, 主题模板在 Root/Theme 下, 缺省模板在 Root/default 下
+	Theme     string
+	Override  bool // 是否覆盖已存在的文件
+
+	ProjectPath    string // 项目的 import 路径
+	BaseController string // 控制器的基类名
+	Layouts        string
+	CustomPath     string
+	ViewTag        string
+	HandlerPrefix  string
+
+	Variables map[string]interface{} // 自定义的模板变量
+	Funcs     template.FuncMap       // 自定义的模板函数
+	Paths     map[string]string      // 输出文件的路径模板, 见 DefaultPaths
+}
+
+// Generator - 代码生成器
+type Generator struct {
+	Options
+	Classes []*types.ClassSpec
+	Sink    Sink
+
+	themePaths map[string]string
+	manifest   Manifest
+}
+
+// New 创建一个代码生成器, classes 为全部类的规格说明, 模板中的 class 函数用它来查找类
+func New(opts Options, classes []*types.ClassSpec, sink Sink) *Generator {
+	return &Generator{Options: opts, Classes: classes, Sink: sink}
+}
+
+// LoadSpecs 读取目录下所有类的规格说明
+func LoadSpecs(root string) ([]*types.ClassSpec, error) {
+	files, err := filepath.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		return nil, errors.New("search root directory fail, " + err.Error())
+	}
+
+	return types.LoadYAMLFiles(files)
+}
+
+// DefaultTemplate 返回内置的模板
+func DefaultTemplate(name string) []byte {
+	return textDefault(name)
+}
+
+// Class 按名称查找类, 没有找到时返回 nil
+func (gen *Generator) Class(name string) *types.ClassSpec {
+	for _, cs := range gen.Classes {
+		if cs.Name == name {
+			return cs
+		}
+	}
+	return nil
+}
+
+// LoadTemplate 查找模板的内容, 依次为主题目录, default 目录和内置的模板
+func (gen *Generator) LoadTemplate(nm string) ([]byte, error) {
+	if gen.Theme != "" {
+		file := filepath.Join(gen.Root, gen.Theme, nm+".tpl.go")
+		bs, e := ioutil.ReadFile(file)
+		if e == nil {
+			return bs, nil
+		}
+		if !os.IsNotExist(e) {
+			return nil, errors.New("load template fail, " + e.Error())
+		}
+	}
+
+	file := filepath.Join(gen.Root, "default", nm+".tpl.go")
+	bs, e := ioutil.ReadFile(file)
+	if e == nil {
+		return bs, nil
+	}
+	if !os.IsNotExist(e) {
+		return nil, errors.New("load template fail, " + e.Error())
+	}
+
+	return textDefault(nm), nil
+}
+
+// NewTemplate 读取并解析模板, funcs 为这个模板专用的函数
+func (gen *Generator) NewTemplate(name string, funcs template.FuncMap) (*template.Template, error) {
+	bs, e := gen.LoadTemplate(name)
+	if e != nil {
+		return nil, e
+	}
+
+	all := template.FuncMap{}
+	for k, v := range gen.Funcs {
+		all[k] = v
+	}
+	for k, v := range funcs {
+		all[k] = v
+	}
+
+	return template.New(name).Delims("[[", "]]").Funcs(all).Funcs(gen.funcs()).Parse(string(bs))
+}
+
+// Execute 依次执行 names 中的模板, 并将结果输出到 filename 中
+func (gen *Generator) Execute(names []string, funcs template.FuncMap, params interface{}, filename string) error {
+	_, err := gen.executeFile(names, funcs, params, filename)
+	return err
+}
+
+// executeFile 同 Execute, 但当文件已存在而跳过时返回 false
+func (gen *Generator) executeFile(names []string, funcs template.FuncMap, params interface{}, filename string) (bool, error) {
+	tpls := make([]*template.Template, 0, len(names))
+	for _, name := range names {
+		tpl, err := gen.NewTemplate(name, funcs)
+		if nil != err {
+			return false, errors.New("load '" + name + "' template," + err.Error())
+		}
+		tpls = append(tpls, tpl)
+	}
+
+	out, err := gen.Sink.Create(filename, gen.Override)
+	if nil != err {
+		if err == ErrExists {
+			fmt.Println("[WARN] [EXISTS] skip", filename)
+			return false, nil
+		}
+		return false, err
+	}
+
+	origins, err := executeAll(out, names, tpls, params)
+	if err != nil {
+		out.Close()
+		gen.Sink.Remove(filename)
+		return false, err
+	}
+	if err := out.Close(); err != nil {
+		return true, err
+	}
+
+	if recorder, ok := gen.Sink.(OriginRecorder); ok {
+		className := originClass(params)
+		for idx := range origins {
+			origins[idx].Class = className
+		}
+		recorder.SetOrigins(filename, origins)
+	}
+	return true, nil
+}
+
+func executeAll(out io.Writer, names []string, tpls []*template.Template, params interface{}) ([]Origin, error) {
+	counter := &lineCounter{w: out}
+	origins := make([]Origin, 0, len(tpls))
+	for idx, tpl := range tpls {
+		start := counter.lines + 1
+		if err := tpl.Execute(counter, params); err != nil {
+			return nil, errors.New("execute '" + names[idx] + "' template," + err.Error())
+		}
+		end := counter.lines
+		if counter.partial {
+			end++
+		}
+		origins = append(origins, Origin{Template: names[idx], StartLine: start, EndLine: end})
+	}
+	return origins, nil
+}
@@ -0,0 +1,346 @@
+package generator
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+var bookClass = &types.ClassSpec{
+	Name: "Book",
+	Fields: []types.FieldSpec{
+		{Name: "id", Type: "objectId"},
+		{Name: "name", Type: "string", IsRequired: true},
+		{Name: "pages", Type: "integer"},
+	},
+}
+
+func TestStructToMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	gen := New(Options{Namespace: "models"}, []*types.ClassSpec{bookClass}, sink)
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, ok := sink.Get("books.go")
+	if !ok {
+		t.Fatal("books.go isn't generated,", sink.Files())
+	}
+	for _, s := range []string{"package models", "type Book struct", "func KeyForBooks"} {
+		if !strings.Contains(string(bs), s) {
+			t.Error("'"+s+"' isn't found in\r\n", string(bs))
+		}
+	}
+}
+
+func TestExecuteSkipExists(t *testing.T) {
+	sink := NewMemorySink()
+	gen := New(Options{Namespace: "models"}, []*types.ClassSpec{bookClass}, sink)
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+
+	gen.Namespace = "other"
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := sink.Get("books.go")
+	if !strings.Contains(string(bs), "package models") {
+		t.Error("existing file is overridden")
+	}
+}
+
+func TestPath(t *testing.T) {
+	gen := New(Options{Namespace: "models",
+		Paths: map[string]string{"struct": "{{.Namespace}}/{{.Underscore}}.go"}}, nil, NewMemorySink())
+
+	for _, test := range []struct {
+		id       string
+		excepted string
+	}{
+		{"struct", "models/book.go"},
+		{"views/index", "Books/index.html"},
+		{"db", "db.go"},
+	} {
+		var cls *types.ClassSpec
+		if test.id != "db" {
+			cls = bookClass
+		}
+		actual, err := gen.Path(test.id, cls)
+		if err != nil {
+			t.Error(test.id, err)
+			continue
+		}
+		if filepath.ToSlash(actual) != test.excepted {
+			t.Error(test.id, "excepted is", test.excepted, "actual is", actual)
+		}
+	}
+
+	if _, err := gen.Path("unknown", bookClass); err == nil {
+		t.Error("excepted error")
+	}
+}
+
+func TestThemeManifest(t *testing.T) {
+	root, err := ioutil.TempDir("", "gengen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.MkdirAll(filepath.Join(root, "mytheme", "views"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "mytheme", ManifestFilename), []byte(`views:
+- id: views/show
+  templates: [views/show]
+  path: "{{.ControllerName}}/show.html"
+- id: views/edit
+  templates: [views/edit]
+  when: ["!editDisabled"]
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "mytheme", "views", "show.tpl.go"),
+		[]byte(`show [[.class.Name]]`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := &types.ClassSpec{Name: "Log",
+		Annotations: map[string]interface{}{"editDisabled": true}}
+	sink := NewMemorySink()
+	gen := New(Options{Root: root, Theme: "mytheme"}, []*types.ClassSpec{bookClass, readOnly}, sink)
+	for _, cls := range gen.Classes {
+		if err := gen.Views(cls); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bs, _ := sink.Get("Books/show.html"); string(bs) != "show Book" {
+		t.Error("show.html is", string(bs))
+	}
+	if _, ok := sink.Get("Books/edit.html"); !ok {
+		t.Error("Books/edit.html isn't generated")
+	}
+	if _, ok := sink.Get("Logs/edit.html"); ok {
+		t.Error("Logs/edit.html is generated")
+	}
+	if _, ok := sink.Get("Books/index.html"); ok {
+		t.Error("Books/index.html is generated")
+	}
+}
+
+func TestStagingCommit(t *testing.T) {
+	output, err := ioutil.TempDir("", "gengen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(output)
+
+	staging := NewStagingSink(DirSink{Dir: output})
+	gen := New(Options{Namespace: "models"}, []*types.ClassSpec{bookClass}, staging)
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "books.go")); !os.IsNotExist(err) {
+		t.Error("books.go is written before commit,", err)
+	}
+
+	// 失败时放弃暂存的文件, 目录中不应留下任何文件
+	gen.Paths = map[string]string{"controller": "{{.Unknown"}
+	if err := gen.Controller(bookClass); err == nil {
+		t.Fatal("excepted error")
+	}
+	staging.Discard()
+	if err := staging.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(output, "*")); len(files) != 0 {
+		t.Error("excepted empty, actual", files)
+	}
+
+	gen.Paths = nil
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(output, "*")); len(files) != 1 || filepath.Base(files[0]) != "books.go" {
+		t.Error("excepted [books.go], actual", files)
+	}
+}
+
+// failingSink - 重命名为 fail 时返回错误的 DirSink
+type failingSink struct {
+	DirSink
+	fail string
+}
+
+func (sink failingSink) Rename(oldname, newname string) error {
+	if newname == sink.fail {
+		return errors.New("rename '" + newname + "' fail")
+	}
+	return sink.DirSink.Rename(oldname, newname)
+}
+
+func TestStagingCommitRollback(t *testing.T) {
+	output, err := ioutil.TempDir("", "gengen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(output)
+
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := ioutil.WriteFile(filepath.Join(output, name), []byte("old "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	staging := NewStagingSink(failingSink{DirSink: DirSink{Dir: output}, fail: "c.go"})
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := writeTo(staging, name, []byte("new "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := staging.Commit(); err == nil {
+		t.Fatal("excepted error")
+	}
+
+	// 已替换的文件应恢复为原来的内容, 不应留下临时文件和备份
+	files, _ := filepath.Glob(filepath.Join(output, "*"))
+	if len(files) != 2 {
+		t.Error("excepted [a.go b.go], actual", files)
+	}
+	for _, name := range []string{"a.go", "b.go"} {
+		bs, err := ioutil.ReadFile(filepath.Join(output, name))
+		if err != nil {
+			t.Error(err)
+		} else if string(bs) != "old "+name {
+			t.Error(name+": excepted", "old "+name, "actual", string(bs))
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gengen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := NewMemorySink()
+	gen := New(Options{Namespace: "models"}, []*types.ClassSpec{bookClass}, sink)
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := CompareGolden(sink, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != DiffExtra {
+		t.Error("excepted extra, actual", diffs)
+	}
+
+	if err := UpdateGolden(sink, dir); err != nil {
+		t.Fatal(err)
+	}
+	if diffs, err := CompareGolden(sink, dir); err != nil || len(diffs) != 0 {
+		t.Error("excepted no diffs, actual", diffs, err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "books.go"), []byte("package models\n\ntype Books struct"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// old.go 是上次生成的文件, README 是手工维护的文件
+	if err := ioutil.WriteFile(filepath.Join(dir, "old.go"), []byte("package models"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, goldenManifest), []byte("books.go\nold.go\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("golden files"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = CompareGolden(sink, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatal("excepted 2 diffs, actual", diffs)
+	}
+	if diffs[0].Kind != DiffChanged || diffs[0].Filename != "books.go" || diffs[0].Line != 3 {
+		t.Error("excepted books.go:3 changed, actual", diffs[0])
+	}
+	if diffs[1].Kind != DiffMissing || diffs[1].Filename != "old.go" {
+		t.Error("excepted old.go missing, actual", diffs[1])
+	}
+
+	// 更新时删除 old.go, 保留 README
+	if err := UpdateGolden(sink, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.go")); !os.IsNotExist(err) {
+		t.Error("old.go isn't removed,", err)
+	}
+	if bs, err := ioutil.ReadFile(filepath.Join(dir, "README")); err != nil || string(bs) != "golden files" {
+		t.Error("README is changed,", string(bs), err)
+	}
+
+	// 只是换行不同时不算差异
+	bs, _ := sink.Get("books.go")
+	if err := ioutil.WriteFile(filepath.Join(dir, "books.go"), []byte(strings.Replace(string(bs), "\n", "\r\n", -1)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if diffs, err := CompareGolden(sink, dir); err != nil || len(diffs) != 0 {
+		t.Error("excepted no diffs, actual", diffs, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	sink := NewMemorySink()
+	gen := New(Options{Namespace: "models"}, []*types.ClassSpec{bookClass}, sink)
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+	errs, err := Verify(sink, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Error("excepted no errors, actual", errs)
+	}
+
+	root, err := ioutil.TempDir("", "gengen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "bad"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "bad", "struct.tpl.go"),
+		[]byte("\nvar x [[.class.Name]] = 1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	sink = NewMemorySink()
+	gen = New(Options{Namespace: "models", Root: root, Theme: "bad"}, []*types.ClassSpec{bookClass}, sink)
+	if err := gen.Struct(bookClass); err != nil {
+		t.Fatal(err)
+	}
+	errs, err = Verify(sink, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Line != 3 || errs[0].Origin == nil ||
+		errs[0].Origin.Template != "struct" || errs[0].Origin.Class != "Book" {
+		t.Error("excepted undefined Book at books.go:3 of the struct template, actual", errs)
+	}
+}
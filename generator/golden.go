@@ -0,0 +1,213 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DiffMissing - golden 文件存在, 但没有生成
+	DiffMissing = "missing"
+	// DiffExtra - 生成了文件, 但 golden 文件不存在
+	DiffExtra = "extra"
+	// DiffChanged - 生成的文件与 golden 文件不同
+	DiffChanged = "changed"
+)
+
+// Diff - 生成的文件与 golden 文件的差异
+type Diff struct {
+	Filename string
+	Kind     string
+	Line     int // 第一个不同的行, 从 1 开始, 只对 DiffChanged 有效
+	Expected string
+	Actual   string
+}
+
+func (diff Diff) String() string {
+	switch diff.Kind {
+	case DiffChanged:
+		return diff.Filename + ":" + strconv.Itoa(diff.Line) + ": changed\r\n" +
+			"  expected: " + diff.Expected + "\r\n" +
+			"  actual:   " + diff.Actual
+	default:
+		return diff.Filename + ": " + diff.Kind
+	}
+}
+
+// goldenManifest - golden 目录下记录生成的文件列表的文件, 不在列表中的文件 (如 README)
+// 是手工维护的, 比较和更新时都会忽略它们
+const goldenManifest = ".gengen-golden"
+
+// readGoldenManifest 读取 golden 目录下生成的文件列表, 没有列表时返回 nil
+func readGoldenManifest(dir string) ([]string, error) {
+	bs, err := ioutil.ReadFile(filepath.Join(dir, goldenManifest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(string(bs), "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// normalizeNewlines 将 \r\n 换行转换为 \n, 以免 git 的 autocrlf 等设置导致比较失败
+func normalizeNewlines(bs []byte) []byte {
+	return bytes.Replace(bs, []byte("\r\n"), []byte("\n"), -1)
+}
+
+// readGoldenFiles 读取 golden 目录下生成的文件, 文件名为相对路径并使用 / 分隔, 没有
+// 文件列表时读取所有的文件
+func readGoldenFiles(dir string) (map[string][]byte, error) {
+	manifest, err := readGoldenManifest(dir)
+	if err != nil {
+		return nil, errors.New("read golden files fail, " + err.Error())
+	}
+	if manifest != nil {
+		files := map[string][]byte{}
+		for _, name := range manifest {
+			bs, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, errors.New("read golden files fail, " + err.Error())
+			}
+			files[name] = bs
+		}
+		return files, nil
+	}
+
+	files := map[string][]byte{}
+	err = filepath.Walk(dir, func(pa string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && pa == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, pa)
+		if err != nil {
+			return err
+		}
+		bs, err := ioutil.ReadFile(pa)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = bs
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("read golden files fail, " + err.Error())
+	}
+	return files, nil
+}
+
+// CompareGolden 比较 sink 中生成的文件与 dir 目录下的 golden 文件
+func CompareGolden(sink *MemorySink, dir string) ([]Diff, error) {
+	golden, err := readGoldenFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []Diff
+	for _, name := range sink.Files() {
+		actual, _ := sink.Get(name)
+		expected, ok := golden[name]
+		if !ok {
+			diffs = append(diffs, Diff{Filename: name, Kind: DiffExtra})
+			continue
+		}
+		delete(golden, name)
+
+		if bytes.Equal(normalizeNewlines(expected), normalizeNewlines(actual)) {
+			continue
+		}
+		diff := Diff{Filename: name, Kind: DiffChanged}
+		diff.Line, diff.Expected, diff.Actual = firstDifferentLine(string(expected), string(actual))
+		diffs = append(diffs, diff)
+	}
+
+	missing := make([]string, 0, len(golden))
+	for name := range golden {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		diffs = append(diffs, Diff{Filename: name, Kind: DiffMissing})
+	}
+	return diffs, nil
+}
+
+func firstDifferentLine(expected, actual string) (int, string, string) {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+	for idx := 0; ; idx++ {
+		var e, a string
+		if idx < len(expectedLines) {
+			e = strings.TrimSuffix(expectedLines[idx], "\r")
+		}
+		if idx < len(actualLines) {
+			a = strings.TrimSuffix(actualLines[idx], "\r")
+		}
+		if e != a || idx >= len(expectedLines) || idx >= len(actualLines) {
+			return idx + 1, e, a
+		}
+	}
+}
+
+// UpdateGolden 用 sink 中生成的文件替换 dir 目录下的 golden 文件, 上次生成而这次没有生成
+// 的文件会被删除, 不是生成的文件 (如 README) 会被保留. 文件先暂存, 全部写入后再替换
+func UpdateGolden(sink *MemorySink, dir string) error {
+	old, err := readGoldenManifest(dir)
+	if err != nil {
+		return errors.New("read golden files fail, " + err.Error())
+	}
+
+	names := sink.Files()
+	sort.Strings(names)
+	generated := map[string]bool{}
+
+	staging := NewStagingSink(DirSink{Dir: dir})
+	for _, name := range names {
+		generated[name] = true
+		bs, _ := sink.Get(name)
+		if err := writeTo(staging, filepath.FromSlash(name), bs); err != nil {
+			return errors.New("write golden file '" + name + "' fail, " + err.Error())
+		}
+	}
+	manifest := strings.Join(names, "\n")
+	if manifest != "" {
+		manifest += "\n"
+	}
+	if err := writeTo(staging, goldenManifest, []byte(manifest)); err != nil {
+		return errors.New("write golden file '" + goldenManifest + "' fail, " + err.Error())
+	}
+	if err := staging.Commit(); err != nil {
+		return errors.New("write golden files fail, " + err.Error())
+	}
+
+	for _, name := range old {
+		if generated[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			return errors.New("remove golden file '" + name + "' fail, " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+func handlerContext(gen *Generator, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{}) {
+	funcs := template.FuncMap{}
+
+	handlerPrefix := gen.HandlerPrefix
+	if handlerPrefix == "" && cls != nil {
+		handlerPrefix = cls.Name
+	}
+
+	params := map[string]interface{}{"namespace": gen.Namespace,
+		"handlerPrefix": handlerPrefix,
+		"class":         cls,
+		"classes":       classes}
+	return funcs, params
+}
+
+// Handler 生成类的结构定义和处理函数
+func (gen *Generator) Handler(cls *types.ClassSpec) error {
+	return gen.Generate("handler", cls)
+}
@@ -0,0 +1,25 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+func jsContext(gen *Generator, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{}) {
+	params := map[string]interface{}{"namespace": gen.Namespace,
+		"classes": classes}
+	if cls != nil {
+		ctlName := types.Pluralize(cls.Name)
+		params["controllerName"] = ctlName
+		params["modelName"] = ctlName
+		params["class"] = cls
+	}
+	funcs := template.FuncMap{}
+	return funcs, params
+}
+
+// JS 生成类的 js 文件
+func (gen *Generator) JS(cls *types.ClassSpec) error {
+	return gen.Generate("js", cls)
+}
@@ -0,0 +1,247 @@
+package generator
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestFilename - 主题目录下的主题清单文件
+const ManifestFilename = "theme.yaml"
+
+const (
+	// ScopeClass - 每个类生成一个文件
+	ScopeClass = "class"
+	// ScopeProject - 整个项目生成一个文件
+	ScopeProject = "project"
+)
+
+// ManifestEntry - 主题清单中的一个输出文件
+type ManifestEntry struct {
+	ID        string   `json:"id" yaml:"id"`
+	Templates []string `json:"templates" yaml:"templates"`
+	// Path 为输出文件的路径模板, 为空时使用 id 对应的路径, 见 DefaultPaths
+	Path  string `json:"path,omitempty" yaml:"path,omitempty"`
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+	// When 为生成这个文件的条件, 它们必须全部满足, 每个条件是类的一个 feature,
+	// 以 ! 开头表示没有这个 feature, 用 | 分隔表示满足其中一个即可,
+	// 如 "!editDisabled|!newDisabled", 条件只对 class 范围的文件有效
+	When []string `json:"when,omitempty" yaml:"when,omitempty"`
+}
+
+// IsProject 是不是整个项目生成一个文件
+func (entry *ManifestEntry) IsProject() bool {
+	return entry.Scope == ScopeProject
+}
+
+// Match 判断类是否满足生成这个文件的条件
+func (entry *ManifestEntry) Match(cls *types.ClassSpec) bool {
+	if cls == nil {
+		return true
+	}
+	for _, cond := range entry.When {
+		matched := false
+		for _, name := range strings.Split(cond, "|") {
+			name = strings.TrimSpace(name)
+			if strings.HasPrefix(name, "!") {
+				matched = !HasFeature(cls, strings.TrimPrefix(name, "!"))
+			} else {
+				matched = HasFeature(cls, name)
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Manifest - 主题清单, 为各个生成器所输出的文件
+type Manifest map[string][]ManifestEntry
+
+// DefaultManifest - 缺省的主题清单, 主题清单中列出的生成器将替换掉这里的同名生成器
+var DefaultManifest = Manifest{
+	"struct": {
+		{ID: "struct", Templates: []string{"ns", "struct"}},
+	},
+	"controller": {
+		{ID: "controller", Templates: []string{"ns", "controller"}},
+	},
+	"handler": {
+		{ID: "handler", Templates: []string{"ns", "struct", "handler"}},
+	},
+	"views": {
+		{ID: "views/index", Templates: []string{"views/index"}},
+		{ID: "views/fields", Templates: []string{"views/fields"}, When: []string{"!editDisabled|!newDisabled"}},
+		{ID: "views/edit", Templates: []string{"views/edit"}, When: []string{"!editDisabled"}},
+		{ID: "views/new", Templates: []string{"views/new"}, When: []string{"!newDisabled"}},
+		{ID: "views/quick", Templates: []string{"views/quick"}},
+	},
+	"js": {
+		{ID: "js", Templates: []string{"views/js"}},
+	},
+	"test": {
+		{ID: "tests/test_ctl", Templates: []string{"tests/test_ctl"}},
+		{ID: "tests/test_yaml", Templates: []string{"tests/test_yaml"}},
+	},
+	"test_base": {
+		{ID: "tests/test_base", Templates: []string{"tests/test_base"}, Scope: ScopeProject},
+	},
+	"db": {
+		{ID: "db", Templates: []string{"ns", "db"}, Scope: ScopeProject},
+	},
+}
+
+// loadManifest 读取主题目录下的 theme.yaml
+func (gen *Generator) loadManifest() (Manifest, error) {
+	manifest := Manifest{}
+	for name, entries := range DefaultManifest {
+		manifest[name] = entries
+	}
+	if gen.Theme == "" {
+		return manifest, nil
+	}
+
+	file := filepath.Join(gen.Root, gen.Theme, ManifestFilename)
+	bs, e := ioutil.ReadFile(file)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return manifest, nil
+		}
+		return nil, errors.New("load manifest fail, " + e.Error())
+	}
+
+	var themeManifest Manifest
+	if e := yaml.Unmarshal(bs, &themeManifest); e != nil {
+		return nil, errors.New("load '" + file + "' fail, " + e.Error())
+	}
+	for name, entries := range themeManifest {
+		for _, entry := range entries {
+			if entry.ID == "" || len(entry.Templates) == 0 {
+				return nil, errors.New("load '" + file + "' fail, id or templates of the " + name + " is missing")
+			}
+			if entry.Scope != "" && entry.Scope != ScopeClass && entry.Scope != ScopeProject {
+				return nil, errors.New("load '" + file + "' fail, scope '" + entry.Scope + "' of the " + entry.ID + " is invalid")
+			}
+		}
+		manifest[name] = entries
+	}
+	return manifest, nil
+}
+
+// Manifest 返回当前主题的清单
+func (gen *Generator) Manifest() (Manifest, error) {
+	if gen.manifest == nil {
+		manifest, err := gen.loadManifest()
+		if err != nil {
+			return nil, err
+		}
+		gen.manifest = manifest
+	}
+	return gen.manifest, nil
+}
+
+// contextFunc 返回生成器的模板函数和模板参数, cls 为 nil 时表示生成整个项目的文件
+type contextFunc func(gen *Generator, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{})
+
+var contexts = map[string]contextFunc{
+	"struct":     structContext,
+	"controller": controllerContext,
+	"handler":    handlerContext,
+	"views":      viewsContext,
+	"js":         jsContext,
+	"test":       unitTestContext,
+}
+
+func (gen *Generator) context(name string, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{}) {
+	if fn, ok := contexts[name]; ok {
+		return fn(gen, cls, classes)
+	}
+
+	funcs := template.FuncMap{
+		"omitempty": omitempty,
+		"tableName": getTableName}
+	params := map[string]interface{}{"namespace": gen.Namespace,
+		"projectPath": gen.ProjectPath,
+		"classes":     classes}
+	if cls != nil {
+		params["class"] = cls
+	}
+	return funcs, params
+}
+
+func (gen *Generator) entries(name string) ([]ManifestEntry, error) {
+	manifest, err := gen.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := manifest[name]
+	if !ok {
+		return nil, errors.New("generator '" + name + "' isn't found")
+	}
+	return entries, nil
+}
+
+// Generate 用生成器 name 生成类 cls 的文件, 即主题清单中 class 范围的文件
+func (gen *Generator) Generate(name string, cls *types.ClassSpec) error {
+	entries, err := gen.entries(name)
+	if err != nil {
+		return err
+	}
+
+	funcs, params := gen.context(name, cls, gen.Classes)
+	return gen.executeEntries(entries, false, cls, funcs, params)
+}
+
+// Project 用生成器 name 生成整个项目的文件, 即主题清单中 project 范围的文件
+func (gen *Generator) Project(name string, classes []*types.ClassSpec) error {
+	entries, err := gen.entries(name)
+	if err != nil {
+		return err
+	}
+
+	funcs, params := gen.context(name, nil, classes)
+	return gen.executeEntries(entries, true, nil, funcs, params)
+}
+
+func (gen *Generator) executeEntries(entries []ManifestEntry, isProject bool, cls *types.ClassSpec, funcs template.FuncMap, params interface{}) error {
+	var generated []string
+	for idx := range entries {
+		entry := &entries[idx]
+		if entry.IsProject() != isProject || !entry.Match(cls) {
+			continue
+		}
+
+		filename, err := gen.entryPath(entry, cls)
+		if err != nil {
+			return errors.New("gen " + entry.ID + ": " + err.Error())
+		}
+		created, err := gen.executeFile(entry.Templates, funcs, params, filename)
+		if err != nil {
+			for _, name := range generated {
+				gen.Sink.Remove(name)
+			}
+			return errors.New("gen " + entry.ID + ": " + err.Error())
+		}
+		if created {
+			generated = append(generated, filename)
+		}
+	}
+	return nil
+}
+
+func (gen *Generator) entryPath(entry *ManifestEntry, cls *types.ClassSpec) (string, error) {
+	if _, ok := gen.Paths[entry.ID]; ok || entry.Path == "" {
+		return gen.Path(entry.ID, cls)
+	}
+	return gen.expandPath(entry.ID, entry.Path, cls)
+}
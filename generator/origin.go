@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+// Origin - 生成文件中的一段代码是由哪个模板和类生成的
+type Origin struct {
+	Template  string
+	Class     string // 为空时表示是整个项目的文件
+	StartLine int    // 起始行, 从 1 开始
+	EndLine   int    // 结束行, 包含这一行
+}
+
+func (origin Origin) String() string {
+	s := "template '" + origin.Template + "'"
+	if origin.Class != "" {
+		s += ", class '" + origin.Class + "'"
+	}
+	return s + ", lines " + strconv.Itoa(origin.StartLine) + "-" + strconv.Itoa(origin.EndLine)
+}
+
+// OriginRecorder - 可以记录生成代码来源的输出目标, 如 StagingSink 和 MemorySink
+type OriginRecorder interface {
+	SetOrigins(filename string, origins []Origin)
+}
+
+// originClass 从模板参数中取出类名
+func originClass(params interface{}) string {
+	if m, ok := params.(map[string]interface{}); ok {
+		if cls, ok := m["class"].(*types.ClassSpec); ok && cls != nil {
+			return cls.Name
+		}
+	}
+	return ""
+}
+
+// originTable 保存各个文件中代码的来源, 调用者负责加锁
+type originTable map[string][]Origin
+
+func (table originTable) lookup(filename string, line int) (Origin, bool) {
+	for _, origin := range table[filename] {
+		if origin.StartLine <= line && line <= origin.EndLine {
+			return origin, true
+		}
+	}
+	return Origin{}, false
+}
+
+// lineCounter 在写入时统计行数
+type lineCounter struct {
+	w       io.Writer
+	lines   int
+	partial bool // 最后一行是否还没有结束
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		c.lines += bytes.Count(p[:n], []byte("\n"))
+		c.partial = p[n-1] != '\n'
+	}
+	return n, err
+}
+
+// SetOrigins 记录文件中代码的来源
+func (d dirSink) SetOrigins(filename string, origins []Origin) {
+	if recorder, ok := d.sink.(OriginRecorder); ok {
+		recorder.SetOrigins(filepath.Join(d.dir, filename), origins)
+	}
+}
@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+	"gopkg.in/yaml.v2"
+)
+
+// PathsFilename - 主题目录下的输出路径配置文件
+const PathsFilename = "paths.yaml"
+
+// DefaultPaths - 各个输出文件的缺省路径, 它们相对于输出目录, 路径是一个模板,
+// 可以使用的变量有 Name, Plural, Underscore, UnderscorePlural, ControllerName,
+// Table 和 Namespace, 例如 "{{.UnderscorePlural}}.go"
+var DefaultPaths = map[string]string{
+	"struct":          "{{.UnderscorePlural}}.go",
+	"controller":      "{{.UnderscorePlural}}.go",
+	"handler":         "{{.Underscore}}.go",
+	"views/index":     "{{.ControllerName}}/index.html",
+	"views/fields":    "{{.ControllerName}}/edit_fields.html",
+	"views/edit":      "{{.ControllerName}}/edit.html",
+	"views/new":       "{{.ControllerName}}/new.html",
+	"views/quick":     "{{.ControllerName}}/quick-bar.html",
+	"js":              "{{.UnderscorePlural}}/{{.UnderscorePlural}}.js",
+	"tests/test_ctl":  "tests/{{.UnderscorePlural}}.go",
+	"tests/test_yaml": "tests/fixtures/{{.UnderscorePlural}}.yaml",
+	"tests/test_base": "tests/test_base.go",
+	"db":              "db.go",
+}
+
+// loadThemePaths 读取主题目录下的 paths.yaml
+func (gen *Generator) loadThemePaths() (map[string]string, error) {
+	if gen.Theme == "" {
+		return nil, nil
+	}
+
+	file := filepath.Join(gen.Root, gen.Theme, PathsFilename)
+	bs, e := ioutil.ReadFile(file)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return nil, nil
+		}
+		return nil, errors.New("load paths fail, " + e.Error())
+	}
+
+	var paths map[string]string
+	if e := yaml.Unmarshal(bs, &paths); e != nil {
+		return nil, errors.New("load '" + file + "' fail, " + e.Error())
+	}
+	return paths, nil
+}
+
+// PathPattern 返回输出文件的路径模板, 依次查找 Options.Paths, 主题的 paths.yaml 和 DefaultPaths
+func (gen *Generator) PathPattern(id string) (string, error) {
+	if pattern, ok := gen.Paths[id]; ok {
+		return pattern, nil
+	}
+
+	if gen.themePaths == nil {
+		paths, err := gen.loadThemePaths()
+		if err != nil {
+			return "", err
+		}
+		if paths == nil {
+			paths = map[string]string{}
+		}
+		gen.themePaths = paths
+	}
+	if pattern, ok := gen.themePaths[id]; ok {
+		return pattern, nil
+	}
+
+	if pattern, ok := DefaultPaths[id]; ok {
+		return pattern, nil
+	}
+	return "", errors.New("path of '" + id + "' isn't found")
+}
+
+// Path 返回输出文件的路径, cls 为 nil 时表示是整个项目的文件
+func (gen *Generator) Path(id string, cls *types.ClassSpec) (string, error) {
+	pattern, err := gen.PathPattern(id)
+	if err != nil {
+		return "", err
+	}
+	return gen.expandPath(id, pattern, cls)
+}
+
+func (gen *Generator) expandPath(id, pattern string, cls *types.ClassSpec) (string, error) {
+	tpl, err := template.New(id).Funcs(template.FuncMap{
+		"underscore":  types.Underscore,
+		"pluralize":   types.Pluralize,
+		"singularize": types.Singularize,
+		"tableize":    types.Tableize,
+	}).Parse(pattern)
+	if err != nil {
+		return "", errors.New("path of '" + id + "' is invalid, " + err.Error())
+	}
+
+	params := map[string]interface{}{"Namespace": gen.Namespace}
+	if cls != nil {
+		params["Name"] = cls.Name
+		params["Plural"] = types.Pluralize(cls.Name)
+		params["Underscore"] = types.Underscore(cls.Name)
+		params["UnderscorePlural"] = types.Underscore(types.Pluralize(cls.Name))
+		params["ControllerName"] = types.Pluralize(cls.Name)
+		params["Table"] = getTableName(cls)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, params); err != nil {
+		return "", errors.New("path of '" + id + "' is invalid, " + err.Error())
+	}
+	return filepath.FromSlash(buf.String()), nil
+}
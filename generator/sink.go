@@ -0,0 +1,183 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrExists - 表示输出文件已存在且不允许覆盖
+var ErrExists = errors.New("file is already exists")
+
+// Sink - 生成代码的输出目标
+type Sink interface {
+	// Create 创建一个输出文件, 当文件已存在且 override 为 false 时返回 ErrExists
+	Create(filename string, override bool) (io.WriteCloser, error)
+
+	// Remove 删除一个输出文件, 生成失败时用它清除不完整的文件
+	Remove(filename string) error
+
+	// Exists 判断输出文件是否已存在
+	Exists(filename string) (bool, error)
+}
+
+// DirSink - 将文件输出到一个目录中
+type DirSink struct {
+	Dir string
+}
+
+func (sink DirSink) path(filename string) string {
+	if filepath.IsAbs(filename) || sink.Dir == "" {
+		return filename
+	}
+	return filepath.Join(sink.Dir, filename)
+}
+
+// Create 创建一个输出文件
+func (sink DirSink) Create(filename string, override bool) (io.WriteCloser, error) {
+	fname := sink.path(filename)
+
+	dirname := filepath.Dir(fname)
+	if dirname != "" {
+		if err := os.MkdirAll(dirname, 0777); err != nil {
+			if !os.IsExist(err) {
+				return nil, err
+			}
+		}
+	}
+
+	var out *os.File
+	var err error
+	if !override {
+		out, err = os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	} else {
+		out, err = os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	}
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrExists
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// Remove 删除一个输出文件
+func (sink DirSink) Remove(filename string) error {
+	return os.Remove(sink.path(filename))
+}
+
+// Exists 判断输出文件是否已存在
+func (sink DirSink) Exists(filename string) (bool, error) {
+	_, err := os.Stat(sink.path(filename))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Rename 重命名一个输出文件
+func (sink DirSink) Rename(oldname, newname string) error {
+	return os.Rename(sink.path(oldname), sink.path(newname))
+}
+
+// MemorySink - 将文件输出到内存中, 主要用于测试
+type MemorySink struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	origins originTable
+}
+
+// NewMemorySink 创建一个 MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: map[string][]byte{}, origins: originTable{}}
+}
+
+type memoryFile struct {
+	bytes.Buffer
+	sink     *MemorySink
+	filename string
+}
+
+func (f *memoryFile) Close() error {
+	f.sink.mu.Lock()
+	defer f.sink.mu.Unlock()
+	f.sink.files[f.filename] = f.Bytes()
+	return nil
+}
+
+// Create 创建一个输出文件
+func (sink *MemorySink) Create(filename string, override bool) (io.WriteCloser, error) {
+	filename = filepath.ToSlash(filename)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, ok := sink.files[filename]; ok && !override {
+		return nil, ErrExists
+	}
+	sink.files[filename] = nil
+	return &memoryFile{sink: sink, filename: filename}, nil
+}
+
+// Remove 删除一个输出文件
+func (sink *MemorySink) Remove(filename string) error {
+	filename = filepath.ToSlash(filename)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, ok := sink.files[filename]; !ok {
+		return os.ErrNotExist
+	}
+	delete(sink.files, filename)
+	delete(sink.origins, filename)
+	return nil
+}
+
+// Exists 判断输出文件是否已存在
+func (sink *MemorySink) Exists(filename string) (bool, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	_, ok := sink.files[filepath.ToSlash(filename)]
+	return ok, nil
+}
+
+// Files 返回已生成的文件名列表
+func (sink *MemorySink) Files() []string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	names := make([]string, 0, len(sink.files))
+	for name := range sink.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get 返回已生成的文件内容
+func (sink *MemorySink) Get(filename string) ([]byte, bool) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	bs, ok := sink.files[filepath.ToSlash(filename)]
+	return bs, ok
+}
+
+// SetOrigins 记录文件中代码的来源
+func (sink *MemorySink) SetOrigins(filename string, origins []Origin) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.origins[filepath.ToSlash(filename)] = origins
+}
+
+// Origin 返回文件中某一行代码的来源
+func (sink *MemorySink) Origin(filename string, line int) (Origin, bool) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink.origins.lookup(filepath.ToSlash(filename), line)
+}
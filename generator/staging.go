@@ -0,0 +1,257 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WithDir 返回一个输出目标, 它将文件输出到 sink 的 dir 目录下
+func WithDir(sink Sink, dir string) Sink {
+	if dir == "" {
+		return sink
+	}
+	return dirSink{sink: sink, dir: dir}
+}
+
+type dirSink struct {
+	sink Sink
+	dir  string
+}
+
+func (d dirSink) Create(filename string, override bool) (io.WriteCloser, error) {
+	return d.sink.Create(filepath.Join(d.dir, filename), override)
+}
+
+func (d dirSink) Remove(filename string) error {
+	return d.sink.Remove(filepath.Join(d.dir, filename))
+}
+
+func (d dirSink) Exists(filename string) (bool, error) {
+	return d.sink.Exists(filepath.Join(d.dir, filename))
+}
+
+// renamer 是可以重命名文件的输出目标, 如 DirSink
+type renamer interface {
+	Rename(oldname, newname string) error
+}
+
+// stagingSuffix - 提交时临时文件的后缀
+const stagingSuffix = ".gengen-staging"
+
+// backupSuffix - 提交时已存在的文件的备份的后缀
+const backupSuffix = ".gengen-backup"
+
+// StagingSink - 先将文件暂存在内存中, 只有调用 Commit 时才写入目标, 这样当生成
+// 失败时, 调用 Discard 放弃暂存的文件就不会在目标中留下不完整的文件
+type StagingSink struct {
+	Target Sink
+
+	mu      sync.Mutex
+	names   []string
+	files   map[string][]byte
+	origins originTable
+}
+
+// NewStagingSink 创建一个 StagingSink
+func NewStagingSink(target Sink) *StagingSink {
+	return &StagingSink{Target: target, files: map[string][]byte{}, origins: originTable{}}
+}
+
+type stagingFile struct {
+	bytes.Buffer
+	sink     *StagingSink
+	filename string
+}
+
+func (f *stagingFile) Close() error {
+	f.sink.mu.Lock()
+	defer f.sink.mu.Unlock()
+	if _, ok := f.sink.files[f.filename]; ok {
+		f.sink.files[f.filename] = f.Bytes()
+	}
+	return nil
+}
+
+// Create 创建一个暂存的输出文件, 当文件在暂存区或目标中已存在且 override 为 false 时返回 ErrExists
+func (sink *StagingSink) Create(filename string, override bool) (io.WriteCloser, error) {
+	filename = filepath.Clean(filename)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	_, staged := sink.files[filename]
+	if !override {
+		if staged {
+			return nil, ErrExists
+		}
+		exists, err := sink.Target.Exists(filename)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrExists
+		}
+	}
+	if !staged {
+		sink.names = append(sink.names, filename)
+	}
+	sink.files[filename] = nil
+	return &stagingFile{sink: sink, filename: filename}, nil
+}
+
+// Remove 删除一个暂存的输出文件
+func (sink *StagingSink) Remove(filename string) error {
+	filename = filepath.Clean(filename)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, ok := sink.files[filename]; !ok {
+		return os.ErrNotExist
+	}
+	delete(sink.files, filename)
+	delete(sink.origins, filename)
+	for idx, name := range sink.names {
+		if name == filename {
+			copy(sink.names[idx:], sink.names[idx+1:])
+			sink.names = sink.names[:len(sink.names)-1]
+			break
+		}
+	}
+	return nil
+}
+
+// Exists 判断输出文件是否在暂存区或目标中已存在
+func (sink *StagingSink) Exists(filename string) (bool, error) {
+	filename = filepath.Clean(filename)
+
+	sink.mu.Lock()
+	_, ok := sink.files[filename]
+	sink.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+	return sink.Target.Exists(filename)
+}
+
+// Files 返回暂存的文件名列表
+func (sink *StagingSink) Files() []string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([]string(nil), sink.names...)
+}
+
+// Get 返回暂存的文件内容
+func (sink *StagingSink) Get(filename string) ([]byte, bool) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	bs, ok := sink.files[filepath.Clean(filename)]
+	return bs, ok
+}
+
+// SetOrigins 记录文件中代码的来源
+func (sink *StagingSink) SetOrigins(filename string, origins []Origin) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.origins[filepath.Clean(filename)] = origins
+}
+
+// Origin 返回暂存文件中某一行代码的来源
+func (sink *StagingSink) Origin(filename string, line int) (Origin, bool) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink.origins.lookup(filepath.Clean(filename), line)
+}
+
+// Discard 放弃所有暂存的文件
+func (sink *StagingSink) Discard() {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.reset()
+}
+
+func (sink *StagingSink) reset() {
+	sink.names = nil
+	sink.files = map[string][]byte{}
+	sink.origins = originTable{}
+}
+
+// Commit 将暂存的文件写入目标, 当目标支持重命名时 (如 DirSink), 先将所有文件
+// 写成临时文件, 全部成功后再重命名为正式的文件名, 重命名失败时恢复原来的文件
+func (sink *StagingSink) Commit() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	r, canRename := sink.Target.(renamer)
+	if !canRename {
+		for _, name := range sink.names {
+			if err := writeTo(sink.Target, name, sink.files[name]); err != nil {
+				return err
+			}
+		}
+		sink.reset()
+		return nil
+	}
+
+	var written []string
+	for _, name := range sink.names {
+		if err := writeTo(sink.Target, name+stagingSuffix, sink.files[name]); err != nil {
+			for _, tmp := range written {
+				sink.Target.Remove(tmp)
+			}
+			sink.Target.Remove(name + stagingSuffix)
+			return errors.New("commit '" + name + "' fail, " + err.Error())
+		}
+		written = append(written, name+stagingSuffix)
+	}
+
+	// 已存在的文件先重命名为备份, 任何一个文件重命名失败时, 删除已替换的文件并恢复备份
+	var backups, renamed []string
+	rollback := func() {
+		for _, name := range renamed {
+			sink.Target.Remove(name)
+		}
+		for _, name := range backups {
+			r.Rename(name+backupSuffix, name)
+		}
+		for _, tmp := range written {
+			sink.Target.Remove(tmp)
+		}
+	}
+	for _, name := range sink.names {
+		exists, err := sink.Target.Exists(name)
+		if err == nil && exists {
+			if err = r.Rename(name, name+backupSuffix); err == nil {
+				backups = append(backups, name)
+			}
+		}
+		if err == nil {
+			err = r.Rename(name+stagingSuffix, name)
+		}
+		if err != nil {
+			rollback()
+			return errors.New("commit '" + name + "' fail, " + err.Error())
+		}
+		renamed = append(renamed, name)
+	}
+	for _, name := range backups {
+		sink.Target.Remove(name + backupSuffix)
+	}
+	sink.reset()
+	return nil
+}
+
+func writeTo(sink Sink, filename string, content []byte) error {
+	out, err := sink.Create(filename, true)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(content); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,21 @@
+package generator
+
+import (
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+func structContext(gen *Generator, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{}) {
+	funcs := template.FuncMap{
+		"omitempty": omitempty}
+	params := map[string]interface{}{"namespace": gen.Namespace,
+		"class":   cls,
+		"classes": classes}
+	return funcs, params
+}
+
+// Struct 生成类的结构定义
+func (gen *Generator) Struct(cls *types.ClassSpec) error {
+	return gen.Generate("struct", cls)
+}
@@ -1,4 +1,4 @@
-package main
+package generator
 
 import "errors"
 
@@ -6,11 +6,15 @@ var embededText = `package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/lann/builder"
@@ -25,12 +29,241 @@ var ErrPrimaryKeyInvalid = errors.New("primary key is invalid")
 // ErrNotDeleted - 表示没有删除任何记录
 var ErrNotDeleted = errors.New("no record is deleted")
 
+// Dialect - 数据库的类型, 它决定了占位符的格式, 标识符的引号, 插入记录后怎样取回
+// 自增的主键, 以及不区分大小写的 LIKE 的写法
+type Dialect string
+
+const (
+	// Postgres - PostgreSQL, 占位符为 $1, 用 RETURNING 取回自增的主键
+	Postgres Dialect = "postgres"
+	// MySQL - MySQL, 占位符为 ?, 标识符用反引号
+	MySQL Dialect = "mysql"
+	// SQLite - SQLite, 占位符为 ?
+	SQLite Dialect = "sqlite"
+)
+
+// DefaultDialect - 无法从 runner 判断数据库类型时 (如 *sql.Tx) 使用的数据库类型
+var DefaultDialect = Postgres
+
+// PlaceholderFormat 返回占位符的格式
+func (d Dialect) PlaceholderFormat() squirrel.PlaceholderFormat {
+	if d == Postgres {
+		return squirrel.Dollar
+	}
+	return squirrel.Question
+}
+
+// Quote 用引号括起标识符, MySQL 用反引号, 其它的用双引号
+func (d Dialect) Quote(name string) string {
+	if d == MySQL {
+		return "` + "`" + `" + strings.Replace(name, "` + "`" + `", "` + "`" + `` + "`" + `", -1) + "` + "`" + `"
+	}
+	return ` + "`" + `"` + "`" + ` + strings.Replace(name, ` + "`" + `"` + "`" + `, ` + "`" + `""` + "`" + `, -1) + ` + "`" + `"` + "`" + `
+}
+
+// HasReturning 判断插入记录时是否用 RETURNING 取回自增的主键, 否则用 LastInsertId
+func (d Dialect) HasReturning() bool {
+	return d == Postgres
+}
+
+// IsDistinctFrom 返回把 NULL 当作普通的值来比较的不等于条件, not 为 true 时为等于条件
+func (d Dialect) IsDistinctFrom(column string, not bool) string {
+	switch d {
+	case MySQL:
+		if not {
+			return column + " <=> ?"
+		}
+		return "NOT (" + column + " <=> ?)"
+	case SQLite:
+		if not {
+			return column + " IS ?"
+		}
+		return column + " IS NOT ?"
+	default:
+		if not {
+			return column + " IS NOT DISTINCT FROM ?"
+		}
+		return column + " IS DISTINCT FROM ?"
+	}
+}
+
+// MaxParams 返回一条语句中最多能有的参数个数, SQLite 3.32 之前的缺省值为 999
+func (d Dialect) MaxParams() int {
+	if d == SQLite {
+		return 999
+	}
+	return 65535
+}
+
+// Upsert 返回插入记录时主键或唯一键冲突后更新 columns 的语句, 它放在 INSERT 语句的后面,
+// columns 为空时忽略冲突的记录. MySQL 不能指定冲突的键, 任何一个唯一键冲突时都会更新
+func (d Dialect) Upsert(keys, columns []string) string {
+	var buf bytes.Buffer
+	if d == MySQL {
+		buf.WriteString("ON DUPLICATE KEY UPDATE ")
+		if len(columns) == 0 {
+			buf.WriteString(d.Quote(keys[0]))
+			buf.WriteString(" = ")
+			buf.WriteString(d.Quote(keys[0]))
+			return buf.String()
+		}
+		for idx, column := range columns {
+			if idx > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(d.Quote(column))
+			buf.WriteString(" = VALUES(")
+			buf.WriteString(d.Quote(column))
+			buf.WriteString(")")
+		}
+		return buf.String()
+	}
+
+	buf.WriteString("ON CONFLICT (")
+	for idx, key := range keys {
+		if idx > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(d.Quote(key))
+	}
+	if len(columns) == 0 {
+		buf.WriteString(") DO NOTHING")
+		return buf.String()
+	}
+	buf.WriteString(") DO UPDATE SET ")
+	for idx, column := range columns {
+		if idx > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(d.Quote(column))
+		buf.WriteString(" = EXCLUDED.")
+		buf.WriteString(d.Quote(column))
+	}
+	return buf.String()
+}
+
+// ILike 返回不区分大小写的 LIKE 条件, PostgreSQL 用 ILIKE, 其它的将两边转换为小写后比较
+func (d Dialect) ILike(column string) string {
+	if d == Postgres {
+		return column + " ILIKE ?"
+	}
+	return "LOWER(" + column + ") LIKE LOWER(?)"
+}
+
+// DialectRunner - 带有数据库类型的 runner, 见 WithDialect, 它同时实现了带 context 和
+// 不带 context 的方法, 内部的 runner 不支持 context 时将忽略 ctx
+type DialectRunner struct {
+	db      interface{}
+	dialect Dialect
+}
+
+// WithDialect 为 runner 指定数据库类型, 用于无法从 runner 判断数据库类型的情况,
+// 如 *sql.Tx, 返回的 runner 可以用在所有需要 runner 的地方
+func WithDialect(db interface{}, dialect Dialect) *DialectRunner {
+	return &DialectRunner{db: db, dialect: dialect}
+}
+
+// toRunner 将 runner 包装为 DialectRunner, 数据库类型仍然从 db 判断
+func toRunner(db interface{}) *DialectRunner {
+	if r, ok := db.(*DialectRunner); ok {
+		return r
+	}
+	return &DialectRunner{db: db}
+}
+
+// Dialect 返回数据库类型
+func (r *DialectRunner) Dialect() Dialect {
+	if r.dialect == "" {
+		return dialectOf(r.db)
+	}
+	return r.dialect
+}
+
+// Exec executes the given query as implemented by database/sql.Exec.
+func (r *DialectRunner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if execer, ok := r.db.(squirrel.Execer); ok {
+		return execer.Exec(query, args...)
+	}
+	return nil, errors.New("runner isn't a Execer")
+}
+
+// Query executes the given query as implemented by database/sql.Query.
+func (r *DialectRunner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if queryer, ok := r.db.(squirrel.Queryer); ok {
+		return queryer.Query(query, args...)
+	}
+	return nil, errors.New("runner isn't a Queryer")
+}
+
+// QueryRow executes the given query as implemented by database/sql.QueryRow.
+func (r *DialectRunner) QueryRow(query string, args ...interface{}) squirrel.RowScanner {
+	switch db := r.db.(type) {
+	case squirrel.QueryRower:
+		return db.QueryRow(query, args...)
+	case squirrel.StdSql:
+		return db.QueryRow(query, args...)
+	default:
+		return &Row{err: errors.New("runner isn't a QueryRower")}
+	}
+}
+
+// ExecContext executes the given query as implemented by database/sql.ExecContext.
+func (r *DialectRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if execer, ok := r.db.(squirrel.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args...)
+	}
+	return r.Exec(query, args...)
+}
+
+// QueryContext executes the given query as implemented by database/sql.QueryContext.
+func (r *DialectRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if queryer, ok := r.db.(squirrel.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args...)
+	}
+	return r.Query(query, args...)
+}
+
+// QueryRowContext executes the given query as implemented by database/sql.QueryRowContext.
+func (r *DialectRunner) QueryRowContext(ctx context.Context, query string, args ...interface{}) squirrel.RowScanner {
+	switch db := r.db.(type) {
+	case squirrel.QueryRowerContext:
+		return db.QueryRowContext(ctx, query, args...)
+	case squirrel.StdSqlCtx:
+		return db.QueryRowContext(ctx, query, args...)
+	default:
+		return r.QueryRow(query, args...)
+	}
+}
+
+// dialectOf 返回 runner 对应的数据库类型
+func dialectOf(db interface{}) Dialect {
+	if d, ok := db.(interface {
+		Dialect() Dialect
+	}); ok {
+		return d.Dialect()
+	}
+	if d, ok := db.(interface {
+		Driver() driver.Driver
+	}); ok {
+		name := strings.ToLower(reflect.TypeOf(d.Driver()).String())
+		switch {
+		case strings.Contains(name, "sqlite"):
+			return SQLite
+		case strings.Contains(name, "mysql"):
+			return MySQL
+		case strings.HasPrefix(name, "*pq."), strings.Contains(name, "postgres"), strings.Contains(name, "pgx"):
+			return Postgres
+		}
+	}
+	return DefaultDialect
+}
+
 func isPostgersql(db interface{}) bool {
-	return true
+	return dialectOf(db) == Postgres
 }
 
 func isPlaceholderWithDollar(value interface{}) bool {
-	return true
+	return dialectOf(value).PlaceholderFormat() == squirrel.Dollar
 }
 
 // Fields 代表多个字段和值
@@ -99,39 +332,89 @@ func ToJSONFromAny(v interface{}) JSON {
 type ViewModel struct {
 	TableName   string
 	ColumnNames []string
+	Dialect     Dialect // 数据库类型, 为空时从 runner 判断, 见 DialectOf
+}
+
+// DialectOf 返回模型在 db 上使用的数据库类型, 模型指定了 Dialect 时使用它
+func (viewModel *ViewModel) DialectOf(db interface{}) Dialect {
+	if viewModel.Dialect != "" {
+		return viewModel.Dialect
+	}
+	return dialectOf(db)
 }
 
 // Count - 统计符合条件的记录数
 func (viewModel *ViewModel) Count(db squirrel.QueryRower, exprs ...Sqlizer) (count int64, err error) {
-	selectBuilder := viewModel.Where(exprs...).Select("count(*)").From(viewModel.TableName)
-	if isPlaceholderWithDollar(db) {
-		selectBuilder = selectBuilder.PlaceholderFormat(squirrel.Dollar)
-	}
-	err = squirrel.QueryRowWith(db, selectBuilder).Scan(&count)
+	return viewModel.CountContext(context.Background(), toRunner(db), exprs...)
+}
+
+// CountContext - 统计符合条件的记录数
+func (viewModel *ViewModel) CountContext(ctx context.Context, db squirrel.QueryRowerContext, exprs ...Sqlizer) (count int64, err error) {
+	dialect := viewModel.DialectOf(db)
+	selectBuilder := viewModel.where(dialect, exprs...).Select("count(*)").From(viewModel.TableName).
+		PlaceholderFormat(dialect.PlaceholderFormat())
+	err = squirrel.QueryRowContextWith(ctx, db, selectBuilder).Scan(&count)
 	return
 }
 
 // Where - 生成查询语句， 如 Where(UserModel.C.NAME.EQU('小明')).Select()
 func (viewModel *ViewModel) Where(exprs ...Sqlizer) squirrel.StatementBuilderType {
+	dialect := viewModel.Dialect
+	if dialect == "" {
+		dialect = DefaultDialect
+	}
+	return viewModel.where(dialect, exprs...)
+}
+
+func (viewModel *ViewModel) where(dialect Dialect, exprs ...Sqlizer) squirrel.StatementBuilderType {
 	if len(exprs) == 0 {
 		return squirrel.StatementBuilder
 	}
 	if len(exprs) == 1 {
-		return builder.Append(squirrel.StatementBuilder, "WhereParts", exprs[0]).(squirrel.StatementBuilderType)
+		return builder.Append(squirrel.StatementBuilder, "WhereParts", withDialect(dialect, exprs[0])).(squirrel.StatementBuilderType)
 	}
 	sqlizers := make([]squirrel.Sqlizer, 0, len(exprs))
 	for _, exp := range exprs {
-		sqlizers = append(sqlizers, exp)
+		sqlizers = append(sqlizers, withDialect(dialect, exp))
 	}
 
 	return builder.Append(squirrel.StatementBuilder, "WhereParts", squirrel.And(sqlizers)).(squirrel.StatementBuilderType)
 }
 
+// withDialect 为没有指定数据库类型的 Expr 设置数据库类型, And, Or 和 Not 中的 Expr 也会设置
+func withDialect(dialect Dialect, sqlizer Sqlizer) Sqlizer {
+	switch expr := sqlizer.(type) {
+	case Expr:
+		if expr.Dialect == "" {
+			expr.Dialect = dialect
+		}
+		return expr
+	case AndExpr:
+		return AndExpr(withDialects(dialect, expr))
+	case OrExpr:
+		return OrExpr(withDialects(dialect, expr))
+	case NotExpr:
+		return NotExpr{Expr: withDialect(dialect, expr.Expr)}
+	default:
+		return sqlizer
+	}
+}
+
+func withDialects(dialect Dialect, exprs []Sqlizer) []Sqlizer {
+	results := make([]Sqlizer, 0, len(exprs))
+	for _, expr := range exprs {
+		results = append(results, withDialect(dialect, expr))
+	}
+	return results
+}
+
 func (viewModel *ViewModel) Update(db squirrel.BaseRunner, values map[string]interface{}, args ...squirrel.Sqlizer) (int64, error) {
+	return viewModel.UpdateContext(context.Background(), toRunner(db), values, args...)
+}
+
+func (viewModel *ViewModel) UpdateContext(ctx context.Context, db BaseRunnerContext, values map[string]interface{}, args ...squirrel.Sqlizer) (int64, error) {
 	sql := squirrel.Update(viewModel.TableName)
-	if isPlaceholderWithDollar(db) {
-		sql = sql.PlaceholderFormat(squirrel.Dollar)
-	}
+	sql = sql.PlaceholderFormat(viewModel.DialectOf(db).PlaceholderFormat())
 
 	for key, value := range values {
 		sql = sql.Set(key, value)
@@ -139,7 +422,7 @@ func (viewModel *ViewModel) Update(db squirrel.BaseRunner, values map[string]int
 
 	sql = sql.Where(squirrel.And(args))
 
-	result, e := sql.RunWith(db).Exec()
+	result, e := sql.RunWith(db).ExecContext(ctx)
 	if nil != e {
 		return 0, e
 	}
@@ -147,10 +430,12 @@ func (viewModel *ViewModel) Update(db squirrel.BaseRunner, values map[string]int
 }
 
 func (viewModel *ViewModel) UpdateBy(db squirrel.BaseRunner, values map[string]interface{}, pred interface{}, args ...interface{}) (int64, error) {
+	return viewModel.UpdateByContext(context.Background(), toRunner(db), values, pred, args...)
+}
+
+func (viewModel *ViewModel) UpdateByContext(ctx context.Context, db BaseRunnerContext, values map[string]interface{}, pred interface{}, args ...interface{}) (int64, error) {
 	sql := squirrel.Update(viewModel.TableName)
-	if isPlaceholderWithDollar(db) {
-		sql = sql.PlaceholderFormat(squirrel.Dollar)
-	}
+	sql = sql.PlaceholderFormat(viewModel.DialectOf(db).PlaceholderFormat())
 
 	for key, value := range values {
 		sql = sql.Set(key, value)
@@ -158,7 +443,7 @@ func (viewModel *ViewModel) UpdateBy(db squirrel.BaseRunner, values map[string]i
 
 	sql = sql.Where(pred, args)
 
-	result, e := sql.RunWith(db).Exec()
+	result, e := sql.RunWith(db).ExecContext(ctx)
 	if nil != e {
 		return 0, e
 	}
@@ -166,11 +451,14 @@ func (viewModel *ViewModel) UpdateBy(db squirrel.BaseRunner, values map[string]i
 }
 
 func (viewModel *ViewModel) Delete(db squirrel.BaseRunner, exprs ...Sqlizer) (int64, error) {
-	sq := viewModel.Where(exprs...).Delete(viewModel.TableName)
-	if isPlaceholderWithDollar(db) {
-		sq = sq.PlaceholderFormat(squirrel.Dollar)
-	}
-	result, e := sq.RunWith(db).Exec()
+	return viewModel.DeleteContext(context.Background(), toRunner(db), exprs...)
+}
+
+func (viewModel *ViewModel) DeleteContext(ctx context.Context, db BaseRunnerContext, exprs ...Sqlizer) (int64, error) {
+	dialect := viewModel.DialectOf(db)
+	sq := viewModel.where(dialect, exprs...).Delete(viewModel.TableName).
+		PlaceholderFormat(dialect.PlaceholderFormat())
+	result, e := sq.RunWith(db).ExecContext(ctx)
 	if nil != e {
 		return 0, e
 	}
@@ -178,12 +466,14 @@ func (viewModel *ViewModel) Delete(db squirrel.BaseRunner, exprs ...Sqlizer) (in
 }
 
 func (viewModel *ViewModel) DeleteBy(db squirrel.BaseRunner, pred interface{}, args ...interface{}) (int64, error) {
+	return viewModel.DeleteByContext(context.Background(), toRunner(db), pred, args...)
+}
+
+func (viewModel *ViewModel) DeleteByContext(ctx context.Context, db BaseRunnerContext, pred interface{}, args ...interface{}) (int64, error) {
 	sq := squirrel.Delete(viewModel.TableName).Where(pred, args)
-	if isPlaceholderWithDollar(db) {
-		sq = sq.PlaceholderFormat(squirrel.Dollar)
-	}
+	sq = sq.PlaceholderFormat(viewModel.DialectOf(db).PlaceholderFormat())
 
-	result, e := sq.RunWith(db).Exec()
+	result, e := sq.RunWith(db).ExecContext(ctx)
 	if nil != e {
 		return 0, e
 	}
@@ -192,14 +482,296 @@ func (viewModel *ViewModel) DeleteBy(db squirrel.BaseRunner, pred interface{}, a
 
 type DbModel struct {
 	ViewModel
-	KeyNames []string
+	KeyNames   []string
+	UniqueKeys map[string][]string // 唯一索引的名称和它的列, 用于 Upsert
+}
+
+// InsertChunkSize - InsertMany 和 Upsert 中一条语句最多插入的记录数, 它还受到数据库的参数个数的限制
+var InsertChunkSize = 1000
+
+// chunkSize 返回一条语句最多插入的记录数
+func chunkSize(dialect Dialect, columnCount int) int {
+	size := InsertChunkSize
+	if columnCount > 0 && size*columnCount > dialect.MaxParams() {
+		size = dialect.MaxParams() / columnCount
+	}
+	if size <= 0 {
+		size = 1
+	}
+	return size
+}
+
+// InsertMany - 批量插入记录, rows 中每一行的值与 columns 一一对应, 记录按 InsertChunkSize
+// 分成多条语句执行, 需要原子性时请在事务中调用
+func (dbModel *DbModel) InsertMany(db squirrel.BaseRunner, columns []string, rows [][]interface{}) (int64, error) {
+	return dbModel.InsertManyContext(context.Background(), toRunner(db), columns, rows)
+}
+
+// InsertManyContext - 批量插入记录, 见 InsertMany
+func (dbModel *DbModel) InsertManyContext(ctx context.Context, db BaseRunnerContext, columns []string, rows [][]interface{}) (int64, error) {
+	return dbModel.insertMany(ctx, db, columns, rows, "")
+}
+
+// Upsert - 批量插入记录, 与已有的记录冲突时更新除冲突的键和 created_at 之外的列,
+// key 为空时按主键判断冲突, 否则按 UniqueKeys 中名为 key 的唯一索引判断
+func (dbModel *DbModel) Upsert(db squirrel.BaseRunner, key string, columns []string, rows [][]interface{}) (int64, error) {
+	return dbModel.UpsertContext(context.Background(), toRunner(db), key, columns, rows)
+}
+
+// UpsertContext - 批量插入或更新记录, 见 Upsert
+func (dbModel *DbModel) UpsertContext(ctx context.Context, db BaseRunnerContext, key string, columns []string, rows [][]interface{}) (int64, error) {
+	keys := dbModel.KeyNames
+	if "" != key {
+		var ok bool
+		if keys, ok = dbModel.UniqueKeys[key]; !ok {
+			return 0, errors.New("unique key '" + key + "' isn't found in '" + dbModel.TableName + "'")
+		}
+	}
+	if len(keys) == 0 {
+		return 0, errors.New("primary key of '" + dbModel.TableName + "' is empty")
+	}
+
+	isKey := map[string]bool{}
+	for _, name := range keys {
+		isKey[name] = true
+	}
+	var updateColumns []string
+	for _, name := range columns {
+		if !isKey[name] && "created_at" != name {
+			updateColumns = append(updateColumns, name)
+		}
+	}
+	return dbModel.insertMany(ctx, db, columns, rows, dbModel.DialectOf(db).Upsert(keys, updateColumns))
+}
+
+func (dbModel *DbModel) insertMany(ctx context.Context, db BaseRunnerContext, columns []string, rows [][]interface{}, suffix string) (int64, error) {
+	for idx, row := range rows {
+		if len(row) != len(columns) {
+			return 0, errors.New("row " + strconv.Itoa(idx) + " of '" + dbModel.TableName + "' has " +
+				strconv.Itoa(len(row)) + " values, excepted " + strconv.Itoa(len(columns)))
+		}
+	}
+
+	dialect := dbModel.DialectOf(db)
+	size := chunkSize(dialect, len(columns))
+	var total int64
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		builder := squirrel.Insert(dbModel.TableName).Columns(columns...).
+			PlaceholderFormat(dialect.PlaceholderFormat())
+		for _, row := range rows[start:end] {
+			builder = builder.Values(row...)
+		}
+		if "" != suffix {
+			builder = builder.Suffix(suffix)
+		}
+
+		result, e := builder.RunWith(db).ExecContext(ctx)
+		if nil != e {
+			return total, e
+		}
+		rowsAffected, e := result.RowsAffected()
+		if nil != e {
+			return total, e
+		}
+		total += rowsAffected
+	}
+	return total, nil
+}
+
+// Direction - 排序的方向
+type Direction string
+
+const (
+	// ASC - 升序
+	ASC Direction = "ASC"
+	// DESC - 降序
+	DESC Direction = "DESC"
+)
+
+type orderBy struct {
+	column ColumnModel
+	dir    Direction
+}
+
+// Query - 查询对象, 记录了查询的条件, 排序和分页, 它的方法会修改它自已并返回它自已.
+// 生成的模型上有带类型的查询对象, 如
+//
+//	UserModel.Query(UserModel.C.NAME.LIKE("a%")).OrderBy(UserModel.C.ID, DESC).Limit(10).All(db)
+type Query struct {
+	model   *ViewModel
+	exprs   []Sqlizer
+	orders  []orderBy
+	limit   uint64
+	offset  uint64
+	after   []interface{}
+	uniques [][]string // 可以用于 keyset 分页的唯一键, 为空时不检查
+}
+
+// Query - 创建查询对象
+func (viewModel *ViewModel) Query(exprs ...Sqlizer) *Query {
+	return &Query{model: viewModel, exprs: exprs}
+}
+
+// Query - 创建查询对象, keyset 分页时排序的列中必须有主键或一个唯一键
+func (dbModel *DbModel) Query(exprs ...Sqlizer) *Query {
+	q := dbModel.ViewModel.Query(exprs...)
+	if len(dbModel.KeyNames) > 0 {
+		q.uniques = append(q.uniques, dbModel.KeyNames)
+	}
+	for _, columns := range dbModel.UniqueKeys {
+		q.uniques = append(q.uniques, columns)
+	}
+	return q
+}
+
+// Where - 增加查询条件
+func (q *Query) Where(exprs ...Sqlizer) *Query {
+	q.exprs = append(q.exprs, exprs...)
+	return q
+}
+
+// OrderBy - 增加排序的列, dir 不为 DESC 时为升序
+func (q *Query) OrderBy(column ColumnModel, dir Direction) *Query {
+	if dir != DESC {
+		dir = ASC
+	}
+	q.orders = append(q.orders, orderBy{column: column, dir: dir})
+	return q
+}
+
+// Limit - 最多返回的记录数, 为 0 时不限制
+func (q *Query) Limit(limit uint64) *Query {
+	q.limit = limit
+	return q
+}
+
+// Offset - 跳过的记录数
+func (q *Query) Offset(offset uint64) *Query {
+	q.offset = offset
+	return q
+}
+
+// After - keyset 分页, 只返回排在 values 之后的记录, values 为上一页最后一条记录的排序的列的值,
+// 与 OrderBy 的列一一对应. 排序的列中必须有主键或一个唯一键, 否则不能保证记录的顺序是确定的
+func (q *Query) After(values ...interface{}) *Query {
+	q.after = values
+	return q
+}
+
+// Paginate - 返回第 page 页 (从 1 开始), 每页 size 条记录的查询对象, 它不会修改原来的对象
+func (q *Query) Paginate(page, size uint64) *Query {
+	paged := *q
+	if page < 1 {
+		page = 1
+	}
+	paged.offset = (page - 1) * size
+	paged.limit = size
+	return &paged
+}
+
+// Count - 统计符合条件的记录数, 排序和分页将被忽略
+func (q *Query) Count(db squirrel.QueryRower) (int64, error) {
+	return q.CountContext(context.Background(), toRunner(db))
+}
+
+// CountContext - 统计符合条件的记录数, 见 Count
+func (q *Query) CountContext(ctx context.Context, db squirrel.QueryRowerContext) (int64, error) {
+	return q.model.CountContext(ctx, db, q.exprs...)
+}
+
+// ToSelect - 生成查询语句, 其中没有列和表名, 它们由模型的 QueryWith 等方法加上
+func (q *Query) ToSelect(dialect Dialect) (squirrel.SelectBuilder, error) {
+	exprs := q.exprs
+	if len(q.after) > 0 {
+		seek, e := q.seek()
+		if nil != e {
+			return squirrel.SelectBuilder{}, e
+		}
+		exprs = append(exprs[:len(exprs):len(exprs)], seek)
+	}
+
+	builder := q.model.where(dialect, exprs...).Select()
+	for _, order := range q.orders {
+		builder = builder.OrderBy(order.column.Name + " " + string(order.dir))
+	}
+	if q.limit > 0 {
+		builder = builder.Limit(q.limit)
+	}
+	if q.offset > 0 {
+		builder = builder.Offset(q.offset)
+	}
+	return builder, nil
+}
+
+// seek 返回 keyset 分页的条件, 如按 a ASC, b DESC 排序时为 a > ? OR (a = ? AND b < ?)
+func (q *Query) seek() (Sqlizer, error) {
+	if len(q.after) != len(q.orders) {
+		return nil, errors.New("keyset pagination of '" + q.model.TableName + "' need " +
+			strconv.Itoa(len(q.orders)) + " values, actual " + strconv.Itoa(len(q.after)))
+	}
+	if !q.isUniqueOrder() {
+		return nil, errors.New("keyset pagination of '" + q.model.TableName + "' must order by a unique key")
+	}
+
+	or := make(squirrel.Or, 0, len(q.orders))
+	for idx, order := range q.orders {
+		and := make(squirrel.And, 0, idx+1)
+		for i := 0; i < idx; i++ {
+			column := &columnModel{origin: &q.orders[i].column}
+			and = append(and, column.EQU(q.after[i]))
+		}
+		column := &columnModel{origin: &q.orders[idx].column}
+		if order.dir == DESC {
+			and = append(and, column.LT(q.after[idx]))
+		} else {
+			and = append(and, column.GT(q.after[idx]))
+		}
+		or = append(or, and)
+	}
+	return or, nil
+}
+
+// isUniqueOrder 判断排序的列中是否有主键或一个唯一键, 模型没有唯一键时不检查
+func (q *Query) isUniqueOrder() bool {
+	if len(q.uniques) == 0 {
+		return true
+	}
+	ordered := map[string]bool{}
+	for _, order := range q.orders {
+		ordered[order.column.Name] = true
+	}
+next:
+	for _, columns := range q.uniques {
+		for _, name := range columns {
+			if !ordered[name] {
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// nullIfZero 值为零值时返回 nil, 用于批量插入时将为零的外键写为 NULL
+func nullIfZero(value interface{}) interface{} {
+	if nil == value || reflect.ValueOf(value).IsZero() {
+		return nil
+	}
+	return value
 }
 
 func (dbModel *DbModel) UpdateByPrimaryKey(db squirrel.BaseRunner, values map[string]interface{}, keys ...interface{}) error {
+	return dbModel.UpdateByPrimaryKeyContext(context.Background(), toRunner(db), values, keys...)
+}
+
+func (dbModel *DbModel) UpdateByPrimaryKeyContext(ctx context.Context, db BaseRunnerContext, values map[string]interface{}, keys ...interface{}) error {
 	sql := squirrel.Update(dbModel.TableName)
-	if isPlaceholderWithDollar(db) {
-		sql = sql.PlaceholderFormat(squirrel.Dollar)
-	}
+	sql = sql.PlaceholderFormat(dbModel.DialectOf(db).PlaceholderFormat())
 
 	for key, value := range values {
 		sql = sql.Set(key, value)
@@ -211,7 +783,7 @@ func (dbModel *DbModel) UpdateByPrimaryKey(db squirrel.BaseRunner, values map[st
 	}
 	sql = sql.Where(cond)
 
-	result, e := sql.RunWith(db).Exec()
+	result, e := sql.RunWith(db).ExecContext(ctx)
 	if nil != e {
 		return e
 	}
@@ -228,16 +800,18 @@ func (dbModel *DbModel) UpdateByPrimaryKey(db squirrel.BaseRunner, values map[st
 }
 
 func (dbModel *DbModel) DeleteByPrimaryKey(db squirrel.BaseRunner, keys ...interface{}) error {
+	return dbModel.DeleteByPrimaryKeyContext(context.Background(), toRunner(db), keys...)
+}
+
+func (dbModel *DbModel) DeleteByPrimaryKeyContext(ctx context.Context, db BaseRunnerContext, keys ...interface{}) error {
 	sql := squirrel.Delete(dbModel.TableName)
-	if isPlaceholderWithDollar(db) {
-		sql = sql.PlaceholderFormat(squirrel.Dollar)
-	}
+	sql = sql.PlaceholderFormat(dbModel.DialectOf(db).PlaceholderFormat())
 	cond := squirrel.Eq{}
 	for idx, key := range keys {
 		cond[dbModel.KeyNames[idx]] = key
 	}
 
-	result, e := sql.Where(cond).RunWith(db).Exec()
+	result, e := sql.Where(cond).RunWith(db).ExecContext(ctx)
 	if nil != e {
 		return e
 	}
@@ -306,11 +880,31 @@ func (model *ColumnModel) IN(values ...interface{}) Expr {
 	return column.IN(values...)
 }
 
+func (model *ColumnModel) NotIN(values ...interface{}) Expr {
+	column := &columnModel{origin: model}
+	return column.NotIN(values...)
+}
+
+func (model *ColumnModel) BETWEEN(min, max interface{}) Expr {
+	column := &columnModel{origin: model}
+	return column.BETWEEN(min, max)
+}
+
 func (model *ColumnModel) NEQ(value interface{}) Expr {
 	column := &columnModel{origin: model}
 	return column.NEQ(value)
 }
 
+func (model *ColumnModel) IsDistinctFrom(value interface{}) Expr {
+	column := &columnModel{origin: model}
+	return column.IsDistinctFrom(value)
+}
+
+func (model *ColumnModel) IsNotDistinctFrom(value interface{}) Expr {
+	column := &columnModel{origin: model}
+	return column.IsNotDistinctFrom(value)
+}
+
 func (model *ColumnModel) EXISTS(value interface{}) Expr {
 	column := &columnModel{origin: model}
 	return column.EXISTS(value)
@@ -321,6 +915,11 @@ func (model *ColumnModel) LIKE(value string) Expr {
 	return column.LIKE(value)
 }
 
+func (model *ColumnModel) ILIKE(value string) Expr {
+	column := &columnModel{origin: model}
+	return column.ILIKE(value)
+}
+
 func (model *ColumnModel) Search(lang, value string) Expr {
 	column := &columnModel{origin: model}
 	return column.Search(lang, value)
@@ -384,26 +983,34 @@ func (model *columnModel) LTE(value interface{}) Expr {
 	return Expr{Column: model, Operator: "<=", Value: value}
 }
 
+// IN 值为空时 ToSql 返回错误
 func (model *columnModel) IN(values ...interface{}) Expr {
-	if len(values) == 0 {
-		panic(errors.New("values is empty"))
-	}
-
-	if len(values) == 1 {
-		val := reflect.ValueOf(values[0])
-		if val.Kind() == reflect.Array || val.Kind() == reflect.Slice {
-			if val.Len() == 0 {
-				panic(errors.New("values is empty"))
-			}
-		}
-	}
 	return Expr{Column: model, Operator: "IN", Value: values}
 }
 
+// NotIN 值为空时 ToSql 返回错误
+func (model *columnModel) NotIN(values ...interface{}) Expr {
+	return Expr{Column: model, Operator: "NOT IN", Value: values}
+}
+
+func (model *columnModel) BETWEEN(min, max interface{}) Expr {
+	return Expr{Column: model, Operator: "BETWEEN", Value: []interface{}{min, max}}
+}
+
 func (model *columnModel) NEQ(value interface{}) Expr {
 	return Expr{Column: model, Operator: "<>", Value: value}
 }
 
+// IsDistinctFrom 把 NULL 当作普通的值来比较的不等于, 不同数据库的写法见 Dialect.IsDistinctFrom
+func (model *columnModel) IsDistinctFrom(value interface{}) Expr {
+	return Expr{Column: model, Operator: "IS DISTINCT FROM", Value: value}
+}
+
+// IsNotDistinctFrom 把 NULL 当作普通的值来比较的等于
+func (model *columnModel) IsNotDistinctFrom(value interface{}) Expr {
+	return Expr{Column: model, Operator: "IS NOT DISTINCT FROM", Value: value}
+}
+
 func (model *columnModel) EXISTS(value interface{}) Expr {
 	return Expr{Column: model, Operator: "EXISTS", Value: value}
 }
@@ -412,17 +1019,23 @@ func (model *columnModel) LIKE(value string) Expr {
 	return Expr{Column: model, Operator: "LIKE", Value: value}
 }
 
+// ILIKE 不区分大小写的 LIKE, 不同数据库的写法见 Dialect.ILike
+func (model *columnModel) ILIKE(value string) Expr {
+	return Expr{Column: model, Operator: "ILIKE", Value: value}
+}
+
 func (model *columnModel) Search(lang, value string) Expr {
 	if "" == lang {
 		lang = "english"
 	}
-	return Expr{Column: model, Operator: "@@", Value: "plainto_tsquery('" + lang + "','" + value + "')"}
+	return Expr{Column: model, Operator: "@@", Value: squirrel.Expr("plainto_tsquery(?::regconfig, ?)", lang, value)}
 }
 
 type Expr struct {
 	Column   *columnModel
 	Operator string
 	Value    interface{}
+	Dialect  Dialect // 为空时使用 DefaultDialect, 在 ViewModel 的方法中会设为模型的数据库类型
 }
 
 func (model Expr) ToSql() (string, []interface{}, error) {
@@ -431,26 +1044,130 @@ func (model Expr) ToSql() (string, []interface{}, error) {
 		if nil != e {
 			return "", nil, e
 		}
+		if "IN" == model.Operator || "NOT IN" == model.Operator {
+			subSqlstr = "(" + subSqlstr + ")"
+		}
 		return model.Column.Name() + " " + model.Operator + " " + subSqlstr, subArgs, nil
 	}
 
-	if "IS" == model.Operator {
+	switch model.Operator {
+	case "IS":
 		return model.Column.Name() + " IS " + fmt.Sprint(model.Value), nil, nil
+	case "ILIKE":
+		return model.dialect().ILike(model.Column.Name()), []interface{}{model.Value}, nil
+	case "IS DISTINCT FROM", "IS NOT DISTINCT FROM":
+		return model.dialect().IsDistinctFrom(model.Column.Name(), "IS NOT DISTINCT FROM" == model.Operator),
+			[]interface{}{model.Value}, nil
+	case "IN", "NOT IN":
+		values := flattenValues(nil, model.Value)
+		if len(values) == 0 {
+			return "", nil, errors.New("ToSql: values is empty in the '" + model.Operator + "' case.")
+		}
+		return model.Column.Name() + " " + model.Operator + " (" +
+			strings.TrimSuffix(strings.Repeat("?,", len(values)), ",") + ")", values, nil
+	case "BETWEEN":
+		values, ok := model.Value.([]interface{})
+		if !ok || len(values) != 2 {
+			return "", nil, errors.New("ToSql: values must be min and max in the 'BETWEEN' case.")
+		}
+		return model.Column.Name() + " BETWEEN ? AND ?", values, nil
+	default:
+		return model.Column.Name() + " " + model.Operator + " ?", []interface{}{model.Value}, nil
+	}
+}
+
+func (model Expr) dialect() Dialect {
+	if model.Dialect == "" {
+		return DefaultDialect
+	}
+	return model.Dialect
+}
+
+// flattenValues 将 IN 的参数展开为一个列表, 参数中的数组和切片 ([]byte 除外) 会被展开
+func flattenValues(results []interface{}, value interface{}) []interface{} {
+	switch inner := value.(type) {
+	case []interface{}:
+		for _, v := range inner {
+			results = flattenValues(results, v)
+		}
+		return results
+	case []byte:
+		return append(results, value)
 	}
-	if "IN" == model.Operator {
-		var buf bytes.Buffer
-		buf.WriteString(model.Column.Name())
-		buf.WriteString(" IN (")
-		oldLength := buf.Len()
-		JoinObjects(&buf, model.Value)
-		if oldLength == buf.Len() {
-			return "", nil, errors.New("ToSql: values is empty in the 'IN' case.")
+
+	valVal := reflect.ValueOf(value)
+	if valVal.Kind() == reflect.Array || valVal.Kind() == reflect.Slice {
+		for i := 0; i < valVal.Len(); i++ {
+			results = append(results, valVal.Index(i).Interface())
 		}
-		buf.Truncate(buf.Len() - 1)
-		buf.WriteString(") ")
-		return buf.String(), nil, nil
+		return results
 	}
-	return model.Column.Name() + " " + model.Operator + " ? ", []interface{}{model.Value}, nil
+	return append(results, value)
+}
+
+// AndExpr - 用 AND 连接的条件, 为空时为真
+type AndExpr []Sqlizer
+
+// And 返回用 AND 连接的条件
+func And(exprs ...Sqlizer) AndExpr {
+	return AndExpr(exprs)
+}
+
+func (exprs AndExpr) ToSql() (string, []interface{}, error) {
+	return joinExprs([]Sqlizer(exprs), " AND ", "(1=1)")
+}
+
+// OrExpr - 用 OR 连接的条件, 为空时为假
+type OrExpr []Sqlizer
+
+// Or 返回用 OR 连接的条件
+func Or(exprs ...Sqlizer) OrExpr {
+	return OrExpr(exprs)
+}
+
+func (exprs OrExpr) ToSql() (string, []interface{}, error) {
+	return joinExprs([]Sqlizer(exprs), " OR ", "(1=0)")
+}
+
+func joinExprs(exprs []Sqlizer, sep, empty string) (string, []interface{}, error) {
+	if len(exprs) == 0 {
+		return empty, nil, nil
+	}
+
+	var buf bytes.Buffer
+	var args []interface{}
+	buf.WriteString("(")
+	for idx, expr := range exprs {
+		sqlStr, exprArgs, e := expr.ToSql()
+		if nil != e {
+			return "", nil, e
+		}
+		if idx > 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteString(sqlStr)
+		args = append(args, exprArgs...)
+	}
+	buf.WriteString(")")
+	return buf.String(), args, nil
+}
+
+// NotExpr - 取反的条件
+type NotExpr struct {
+	Expr Sqlizer
+}
+
+// Not 返回取反的条件
+func Not(expr Sqlizer) NotExpr {
+	return NotExpr{Expr: expr}
+}
+
+func (not NotExpr) ToSql() (string, []interface{}, error) {
+	sqlStr, args, e := not.Expr.ToSql()
+	if nil != e {
+		return "", nil, e
+	}
+	return "NOT (" + sqlStr + ")", args, nil
 }
 
 type existsExpr struct {
@@ -469,6 +1186,9 @@ func EXISTS(sqlizer squirrel.Sqlizer) *existsExpr {
 	return &existsExpr{sqlizer: sqlizer}
 }
 
+// JoinObjects 将值用逗号连接起来, 它没有转义, 不能用于生成 SQL 语句.
+//
+// Deprecated: IN 已经改为用参数传值, 见 flattenValues
 func JoinObjects(buf *bytes.Buffer, value interface{}) {
 	if inner, ok := value.([]interface{}); ok {
 		for _, v := range inner {
@@ -550,6 +1270,43 @@ type Runner interface {
 	QueryRower
 }
 
+// ExecerContext is the interface that wraps the ExecContext method.
+//
+// ExecContext executes the given query as implemented by database/sql.ExecContext.
+type ExecerContext interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// QueryerContext is the interface that wraps the QueryContext method.
+//
+// QueryContext executes the given query as implemented by database/sql.QueryContext.
+type QueryerContext interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// QueryRowerContext is the interface that wraps the QueryRowContext method.
+//
+// QueryRowContext executes the given query as implemented by database/sql.QueryRowContext.
+type QueryRowerContext interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner
+}
+
+// BaseRunnerContext groups the Execer, Queryer, ExecerContext and QueryerContext interfaces,
+// both *sql.DB and *sql.Tx implement it.
+type BaseRunnerContext interface {
+	BaseRunner
+	ExecerContext
+	QueryerContext
+}
+
+// RunnerContext groups the Runner, ExecerContext, QueryerContext, and QueryRowerContext interfaces.
+type RunnerContext interface {
+	Runner
+	ExecerContext
+	QueryerContext
+	QueryRowerContext
+}
+
 // RowScanner is the interface that wraps the Scan method.
 //
 // Scan behaves like database/sql.Row.Scan.
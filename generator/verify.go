@@ -0,0 +1,399 @@
+package generator
+
+import (
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source - 可以读取生成文件的输出目标, 如 StagingSink 和 MemorySink
+type Source interface {
+	Files() []string
+	Get(filename string) ([]byte, bool)
+	Origin(filename string, line int) (Origin, bool)
+}
+
+// VerifyError - 生成的 go 代码中的一个错误
+type VerifyError struct {
+	Filename string
+	Line     int
+	Column   int
+	Msg      string
+	Origin   *Origin // 产生这一行代码的模板和类, 为 nil 表示不是本次生成的代码
+}
+
+func (e VerifyError) String() string {
+	s := e.Filename + ":" + strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column) + ": " + e.Msg
+	if e.Origin != nil {
+		s += " (" + e.Origin.String() + ")"
+	}
+	return s
+}
+
+// errStubbed - 无法导入的包用一个空的包代替, go/types 不会报告使用它而产生的错误
+const errStubbed = "package is stubbed"
+
+// verifyPackage 是同一目录下的同一个包
+type verifyPackage struct {
+	dir   string
+	name  string
+	files []*ast.File
+	pkg   *types.Package
+	done  bool
+}
+
+type verifier struct {
+	src      Source
+	fset     *token.FileSet
+	std      types.Importer
+	packages []*verifyPackage
+	imported map[string]*types.Package
+	errors   []VerifyError
+}
+
+// Verify 解析并检查 src 中生成的 go 代码, 同一目录下的文件属于同一个包, 当
+// existing 为 true 时, 同一目录下磁盘上已有的 go 文件也参与检查。标准库之外
+// 的包如果不是本次生成的, 就用一个空的包代替, 使用它们的代码将不被检查
+func Verify(src Source, existing bool) ([]VerifyError, error) {
+	v := &verifier{src: src,
+		fset:     token.NewFileSet(),
+		imported: map[string]*types.Package{}}
+	v.std = importer.ForCompiler(v.fset, "source", nil)
+
+	byDir := map[string][]string{}
+	for _, name := range src.Files() {
+		if strings.HasSuffix(name, ".go") {
+			dir := filepath.Dir(name)
+			byDir[dir] = append(byDir[dir], name)
+		}
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		names := byDir[dir]
+		if existing {
+			others, err := existingFiles(dir, names)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, others...)
+		}
+
+		for _, name := range names {
+			if err := v.parse(dir, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, pkg := range v.packages {
+		v.check(pkg)
+	}
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		if v.errors[i].Filename != v.errors[j].Filename {
+			return v.errors[i].Filename < v.errors[j].Filename
+		}
+		return v.errors[i].Line < v.errors[j].Line
+	})
+	return v.errors, nil
+}
+
+// existingFiles 返回目录下已有的, 并且不是本次生成的 go 文件
+func existingFiles(dir string, generated []string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, errors.New("search '" + dir + "' fail, " + err.Error())
+	}
+
+	var names []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		found := false
+		for _, name := range generated {
+			if filepath.Clean(name) == filepath.Clean(file) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, file)
+		}
+	}
+	return names, nil
+}
+
+func (v *verifier) parse(dir, name string) error {
+	bs, ok := v.src.Get(name)
+	if !ok {
+		var err error
+		bs, err = ioutil.ReadFile(name)
+		if err != nil {
+			return errors.New("read '" + name + "' fail, " + err.Error())
+		}
+	}
+
+	file, err := parser.ParseFile(v.fset, name, bs, parser.AllErrors)
+	if err != nil {
+		if list, ok := err.(scanner.ErrorList); ok {
+			for _, e := range list {
+				v.addError(e.Pos, e.Msg)
+			}
+		} else {
+			v.errors = append(v.errors, VerifyError{Filename: name, Msg: err.Error()})
+		}
+		if file == nil {
+			return nil
+		}
+	}
+
+	for _, pkg := range v.packages {
+		if pkg.dir == dir && pkg.name == file.Name.Name {
+			pkg.files = append(pkg.files, file)
+			return nil
+		}
+	}
+	v.packages = append(v.packages, &verifyPackage{dir: dir, name: file.Name.Name, files: []*ast.File{file}})
+	return nil
+}
+
+func (v *verifier) addError(pos token.Position, msg string) {
+	e := VerifyError{Filename: pos.Filename, Line: pos.Line, Column: pos.Column, Msg: msg}
+	if origin, ok := v.src.Origin(pos.Filename, pos.Line); ok {
+		e.Origin = &origin
+	}
+	v.errors = append(v.errors, e)
+}
+
+func (v *verifier) check(pkg *verifyPackage) *types.Package {
+	if pkg.done {
+		return pkg.pkg
+	}
+	pkg.done = true
+	addMissingImports(pkg.files)
+
+	var errs []types.Error
+	conf := types.Config{
+		Importer: importerFunc(v.importPackage),
+		Error: func(err error) {
+			e, ok := err.(types.Error)
+			if !ok {
+				v.errors = append(v.errors, VerifyError{Filename: pkg.dir, Msg: err.Error()})
+				return
+			}
+			if strings.Contains(e.Msg, errStubbed) {
+				return
+			}
+			errs = append(errs, e)
+		},
+	}
+	pkg.pkg, _ = conf.Check(filepath.ToSlash(pkg.dir), v.fset, pkg.files, nil)
+
+	for _, e := range errs {
+		// goimports 会删除没有使用的导入
+		if strings.Contains(e.Msg, "imported and not used") || isStubbedMember(pkg.pkg, e.Msg) {
+			continue
+		}
+		v.addError(e.Fset.Position(e.Pos), e.Msg)
+	}
+	return pkg.pkg
+}
+
+var noMemberRe = regexp.MustCompile(`\(type \*?([\w./]+) has no field or method`)
+
+// isStubbedMember 判断错误是不是因为访问了从空的包中的类型继承来的字段或方法,
+// 如 c.Render undefined (type Books has no field or method Render), 而 Books
+// 嵌入了 *revel.Controller
+func isStubbedMember(pkg *types.Package, msg string) bool {
+	if pkg == nil {
+		return false
+	}
+	matches := noMemberRe.FindStringSubmatch(msg)
+	if matches == nil {
+		return false
+	}
+	name := matches[1]
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	obj := pkg.Scope().Lookup(name)
+	if obj == nil {
+		return false
+	}
+	return embedsStub(obj.Type(), map[types.Type]bool{})
+}
+
+func embedsStub(typ types.Type, seen map[types.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if typ == types.Typ[types.Invalid] {
+		return true
+	}
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if field := st.Field(i); field.Embedded() && embedsStub(field.Type(), seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// stdPackageNames - 模板中常用的标准库的包名, 用于推断没有导入的包
+var stdPackageNames = map[string]string{
+	"sql":      "database/sql",
+	"json":     "encoding/json",
+	"http":     "net/http",
+	"url":      "net/url",
+	"filepath": "path/filepath",
+	"ioutil":   "io/ioutil",
+}
+
+// addMissingImports 同 goimports 一样, 为使用了但没有导入的包加上导入, 生成的代码
+// 在编译前都会用 goimports 格式化, 模板中通常不会写完整的导入
+func addMissingImports(files []*ast.File) {
+	declared := map[string]bool{}
+	for _, file := range files {
+		for name := range file.Scope.Objects {
+			declared[name] = true
+		}
+	}
+
+	for _, file := range files {
+		imported := map[string]bool{}
+		for _, spec := range file.Imports {
+			name := path.Base(strings.Trim(spec.Path.Value, "\""))
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			imported[name] = true
+		}
+
+		unresolved := map[*ast.Ident]bool{}
+		for _, ident := range file.Unresolved {
+			unresolved[ident] = true
+		}
+
+		var missing []string
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			ident, ok := sel.X.(*ast.Ident)
+			if !ok || !unresolved[ident] || declared[ident.Name] || imported[ident.Name] ||
+				types.Universe.Lookup(ident.Name) != nil {
+				return true
+			}
+			imported[ident.Name] = true
+			missing = append(missing, ident.Name)
+			return true
+		})
+		if len(missing) == 0 {
+			continue
+		}
+
+		decl := &ast.GenDecl{Tok: token.IMPORT}
+		for _, name := range missing {
+			importPath := name
+			if p, ok := stdPackageNames[name]; ok {
+				importPath = p
+			}
+			spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(importPath)}}
+			if path.Base(importPath) != name {
+				spec.Name = ast.NewIdent(name)
+			}
+			decl.Specs = append(decl.Specs, spec)
+			file.Imports = append(file.Imports, spec)
+		}
+		file.Decls = append([]ast.Decl{decl}, file.Decls...)
+	}
+}
+
+// importPackage 导入一个包, 依次查找标准库和本次检查的包, 找不到时用一个空的包代替,
+// 本次检查的包按目录匹配, 如 "myproject/app/models" 匹配目录 output/app/models
+func (v *verifier) importPackage(importPath string) (*types.Package, error) {
+	if pkg, ok := v.imported[importPath]; ok {
+		return pkg, nil
+	}
+
+	if isStdPackage(importPath) {
+		pkg, err := v.std.Import(importPath)
+		if err != nil {
+			return nil, err
+		}
+		v.imported[importPath] = pkg
+		return pkg, nil
+	}
+
+	var found *verifyPackage
+	var foundLen int
+	for _, pkg := range v.packages {
+		if strings.HasSuffix(pkg.name, "_test") {
+			continue
+		}
+		if n := commonSuffix(importPath, pkg.dir); n > foundLen {
+			found, foundLen = pkg, n
+		}
+	}
+	if found != nil {
+		if checked := v.check(found); checked != nil {
+			v.imported[importPath] = checked
+			return checked, nil
+		}
+	}
+	return nil, errors.New(errStubbed)
+}
+
+// commonSuffix 返回导入路径与目录末尾相同的元素个数
+func commonSuffix(importPath, dir string) int {
+	a := strings.Split(importPath, "/")
+	b := strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/")
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+func isStdPackage(importPath string) bool {
+	if importPath == "C" || strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+		return false
+	}
+	st, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path.Clean(importPath))))
+	return err == nil && st.IsDir()
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (fn importerFunc) Import(path string) (*types.Package, error) {
+	return fn(path)
+}
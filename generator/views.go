@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/three-plus-three/gengen/types"
+)
+
+func viewsContext(gen *Generator, cls *types.ClassSpec, classes []*types.ClassSpec) (template.FuncMap, map[string]interface{}) {
+	viewTag := gen.ViewTag
+	if viewTag != "" {
+		viewTag = "_" + viewTag
+	}
+	params := map[string]interface{}{"namespace": gen.Namespace,
+		"theme":      viewTag,
+		"layouts":    gen.Layouts,
+		"customPath": gen.CustomPath,
+		"classes":    classes}
+	if cls != nil {
+		ctlName := types.Pluralize(cls.Name)
+		params["controllerName"] = ctlName
+		params["modelName"] = ctlName
+		params["class"] = cls
+	}
+	funcs := template.FuncMap{"localizeName": localizeName,
+		"index_label": func(cls *types.ClassSpec) string {
+			if cls.IndexLabel != "" {
+				return cls.IndexLabel
+			}
+			return localizeName(cls)
+		},
+		"new_label": func(cls *types.ClassSpec) string {
+			if cls.NewLabel != "" {
+				return cls.NewLabel
+			}
+			return "新建" + localizeName(cls)
+		},
+		"edit_label": func(cls *types.ClassSpec) string {
+			if cls.EditLabel != "" {
+				return cls.EditLabel
+			}
+			return "编辑" + localizeName(cls)
+		},
+		"isClob": func(f types.FieldSpec) bool {
+			if f.Restrictions != nil {
+				if f.Restrictions.Length > 500 {
+					return true
+				}
+				if f.Restrictions.MaxLength > 500 {
+					return true
+				}
+			}
+			return false
+		},
+		"isID": isID,
+		"needDisplay": func(f types.FieldSpec) bool {
+			for k, ann := range f.Annotations {
+				if k == "noshow" {
+					if v := strings.ToLower(fmt.Sprint(ann)); v == "true" || v == "yes" {
+						return false
+					}
+				}
+			}
+
+			if f.Name == "id" {
+				return false
+			}
+			if f.Type == "password" {
+				return false
+			}
+			return true
+		},
+		"jsEnumeration": func(enumerationValues []types.EnumerationValue) string {
+			bs, err := json.Marshal(enumerationValues)
+			if err != nil {
+				panic(err)
+			}
+			return string(bs)
+		},
+		"belongsToClassName": func(cls *types.ClassSpec, f types.FieldSpec) string {
+			for _, belongsTo := range cls.BelongsTo {
+				if belongsTo.Name == f.Name {
+					return belongsTo.Target
+				}
+			}
+			return ""
+		}}
+
+	return funcs, params
+}
+
+// Views 生成类的视图
+func (gen *Generator) Views(cls *types.ClassSpec) error {
+	return gen.Generate("views", cls)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/three-plus-three/gengen/generator"
+	"github.com/three-plus-three/gengen/types"
+)
+
+// goldenNamespaces - golden 测试中各个生成器的包名, 同 mvc 命令
+var goldenNamespaces = map[string]string{
+	"struct":     "models",
+	"handler":    "models",
+	"db":         "models",
+	"controller": "controllers",
+	"views":      "views",
+	"js":         "js",
+	"test":       "tests",
+	"test_base":  "tests",
+}
+
+// GoldenCommand - 用样例的规格说明运行生成器, 并将结果与 golden 文件比较
+type GoldenCommand struct {
+	baseCommand
+	specs       string
+	golden      string
+	generators  string
+	update      bool
+	controller  string
+	projectPath string
+}
+
+// Flags - 申明参数
+func (cmd *GoldenCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	fs.StringVar(&cmd.specs, "specs", "", "the directory of the sample specs, default is root")
+	fs.StringVar(&cmd.golden, "golden", "golden", "the directory of the golden files")
+	fs.StringVar(&cmd.generators, "generators", "struct,controller,views,js,test,test_base,db", "the generators, separated by commas")
+	fs.BoolVar(&cmd.update, "update", false, "update the golden files")
+	fs.StringVar(&cmd.controller, "controller", "", "the base controller name")
+	fs.StringVar(&cmd.projectPath, "projectPath", "", "the project path")
+	return cmd.baseCommand.Flags(fs)
+}
+
+// Run - 运行 golden 测试
+func (cmd *GoldenCommand) Run(args []string) error {
+	if err := cmd.loadConfig("golden"); err != nil {
+		return err
+	}
+
+	specs := cmd.specs
+	if specs == "" {
+		specs = cmd.root
+	}
+	classes, err := generator.LoadSpecs(specs)
+	if err != nil {
+		return err
+	}
+	if len(classes) == 0 {
+		return errors.New("specs isn't found in the '" + specs + "'")
+	}
+
+	failed := 0
+	for _, name := range strings.Split(cmd.generators, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		sink := generator.NewMemorySink()
+		if err := cmd.generateAll(name, classes, sink); err != nil {
+			return errors.New("gen " + name + ": " + err.Error())
+		}
+
+		dir := filepath.Join(cmd.golden, name)
+		if cmd.update {
+			if err := generator.UpdateGolden(sink, dir); err != nil {
+				return err
+			}
+			fmt.Println("[UPDATE]", name, len(sink.Files()), "files")
+			continue
+		}
+
+		diffs, err := generator.CompareGolden(sink, dir)
+		if err != nil {
+			return err
+		}
+		if len(diffs) == 0 {
+			fmt.Println("[PASS]", name)
+			continue
+		}
+		fmt.Println("[FAIL]", name)
+		for _, diff := range diffs {
+			fmt.Println("  ", name+"/"+diff.String())
+		}
+		failed += len(diffs)
+	}
+
+	if failed > 0 {
+		return errors.New(strconv.Itoa(failed) + " files are different from the golden files, run with -update to accept them")
+	}
+	return nil
+}
+
+func (cmd *GoldenCommand) generateAll(name string, classes []*types.ClassSpec, sink generator.Sink) error {
+	opts := cmd.options()
+	opts.Override = true
+	opts.BaseController = cmd.controller
+	opts.ProjectPath = cmd.projectPath
+	// 命令行或配置文件中指定了 namespace 时, 所有生成器都使用它
+	if !isFlagSet(cmd.flags, "namespace") {
+		if ns, ok := goldenNamespaces[name]; ok {
+			opts.Namespace = ns
+		}
+	}
+
+	gen := generator.New(opts, classes, sink)
+	for _, cls := range classes {
+		if err := gen.Generate(name, cls); err != nil {
+			return err
+		}
+	}
+	return gen.Project(name, classes)
+}
@@ -1,12 +1,6 @@
 package main
 
-import (
-	"flag"
-	"path/filepath"
-	"text/template"
-
-	"github.com/three-plus-three/gengen/types"
-)
+import "flag"
 
 // HandlerCommand - 生成控制器
 type HandlerCommand struct {
@@ -22,18 +16,11 @@ func (cmd *HandlerCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
 
 // Run - 生成代码
 func (cmd *HandlerCommand) Run(args []string) error {
-	return cmd.run(args, func(cls *types.ClassSpec) error {
-		funcs := template.FuncMap{}
-
-		if cmd.handlerPrefix == "" {
-			cmd.handlerPrefix = cls.Name
-		}
-
-		params := map[string]interface{}{"namespace": cmd.ns,
-			"handlerPrefix": cmd.handlerPrefix,
-			"class":         cls}
+	if err := cmd.loadConfig("handler"); err != nil {
+		return err
+	}
 
-		return cmd.executeTempate(cmd.override, []string{"ns", "struct", "handler"}, funcs, params,
-			filepath.Join(cmd.output, Underscore(cls.Name)+".go"))
-	})
+	opts := cmd.options()
+	opts.HandlerPrefix = cmd.handlerPrefix
+	return cmd.run(args, "handler", opts)
 }
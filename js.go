@@ -1,13 +1,6 @@
 package main
 
-import (
-	"errors"
-	"flag"
-	"path/filepath"
-	"text/template"
-
-	"github.com/three-plus-three/gengen/types"
-)
+import "flag"
 
 // GenerateJSCommand - 生成视图
 type GenerateJSCommand struct {
@@ -21,21 +14,12 @@ func (cmd *GenerateJSCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
 
 // Run - 生成代码
 func (cmd *GenerateJSCommand) Run(args []string) error {
-	return cmd.run(args, cmd.genrateJS)
+	if err := cmd.loadConfig("js"); err != nil {
+		return err
+	}
+	return cmd.generate(args)
 }
 
-func (cmd *GenerateJSCommand) genrateJS(cls *types.ClassSpec) error {
-	ctlName := Pluralize(cls.Name)
-	params := map[string]interface{}{"namespace": cmd.ns,
-		"controllerName": ctlName,
-		"modelName":      ctlName,
-		"class":          cls}
-	funcs := template.FuncMap{}
-
-	err := cmd.executeTempate(cmd.override, []string{"views/js"}, funcs, params,
-		filepath.Join(cmd.output, Underscore(Pluralize(cls.Name)), Underscore(Pluralize(cls.Name))+".js"))
-	if err != nil {
-		return errors.New("gen views/js: " + err.Error())
-	}
-	return nil
+func (cmd *GenerateJSCommand) generate(args []string) error {
+	return cmd.run(args, "js", cmd.options())
 }
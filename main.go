@@ -47,7 +47,9 @@ func (cmd *embedeCommand) Run(args []string) error {
 		return e
 	}
 	defer out.Close()
-	out.WriteString(`package main
+	out.WriteString(`package generator
+
+import "errors"
 `)
 
 	templates := [][3]string{{"base", "embededText", "base.go"},
@@ -115,6 +117,8 @@ func init() {
 	command.On("embeded", "", &embedeCommand{}, nil)
 	//command.On("generate", "从数据库的表模型生成控制器和 views 代码", &generateCommand{}, nil)
 	command.On("models", "从数据库的表模型生成 models 代码", &GenerateModelsCommand{}, nil)
+	command.On("specs", "从数据库的表模型生成类的规格说明", &GenerateSpecsCommand{}, nil)
+	command.On("snapshot", "将数据库的表结构保存为快照, 其它命令可以用 -snapshot 参数读取它", &GenerateSnapshotCommand{}, nil)
 	command.On("controller", "从数据库的表模型生成控制器代码", &GenerateControllerCommand{}, nil)
 	command.On("views", "从数据库的表模型生成 Views 代码", &GenerateViewCommand{}, nil)
 	command.On("struct", "", &GenerateStructCommand{}, nil)
@@ -122,6 +126,7 @@ func init() {
 	command.On("mvc", "", &GenerateMVCCommand{}, nil)
 	command.On("test", "", &GenerateUnitTestCommand{}, nil)
 	command.On("test_base", "", &GenerateTestCommand{}, nil)
+	command.On("golden", "用样例的规格说明运行生成器, 并与 golden 文件比较", &GoldenCommand{}, nil)
 }
 
 func main() {
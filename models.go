@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"flag"
+	"go/token"
 	"io"
 	"log"
 	"os"
@@ -12,29 +13,101 @@ import (
 	"reflect"
 	"strings"
 	"text/template"
+
+	"github.com/three-plus-three/gengen/generator"
 )
 
 // GenerateModelsCommand - 生成数据库模型代码
 type GenerateModelsCommand struct {
 	dbBase
-	ns   string
-	file string
+	ns              string
+	file            string
+	nullable        string
+	nullableColumns string
+	split           bool
+	variables       map[string]interface{}
 
 	root           string
+	flags          *flag.FlagSet
 	templateHeader *template.Template
 	templateModel  *template.Template
 }
 
 // Flags - 申明参数
 func (cmd *GenerateModelsCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.flags = fs
 	cmd.initFlags(fs)
 	fs.StringVar(&cmd.ns, "namespace", "models", "the namespace name")
 	fs.StringVar(&cmd.file, "file", "models.go", "the output target")
+	fs.StringVar(&cmd.nullable, "nullable", nullZero, "the type of nullable columns, zero, pointer or sql")
+	fs.BoolVar(&cmd.split, "split", false, "write one file per table into the directory of -file, base.go is copied once")
+	fs.StringVar(&cmd.nullableColumns, "nullable_columns", "", "the type of some nullable columns, such as tpt_books.price=pointer,tpt_books.isbn=sql")
 	return fs
 }
 
+// 可以为 NULL 的列在生成的结构中的类型
+const (
+	nullZero    = "zero"    // 字段为 go 类型, NULL 读为零值
+	nullPointer = "pointer" // 字段为 go 类型的指针, NULL 为 nil
+	nullSQL     = "sql"     // 字段为 sql.NullString 等类型
+)
+
+func isNullStrategy(s string) bool {
+	return s == nullZero || s == nullPointer || s == nullSQL
+}
+
+// initNullable 按 -nullable 和 -nullable_columns 设置可以为 NULL 的列的 NullStrategy,
+// 只有基本类型的列才需要设置, []byte, net.IP 和 JSON 等类型可以直接用 nil 表示 NULL
+func (cmd *GenerateModelsCommand) initNullable(tables []Table) error {
+	if !isNullStrategy(cmd.nullable) {
+		return errors.New("nullable '" + cmd.nullable + "' is unsupported")
+	}
+	byColumn := map[string]string{}
+	for _, s := range strings.Split(cmd.nullableColumns, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		idx := strings.Index(s, "=")
+		if idx < 0 || !isNullStrategy(strings.TrimSpace(s[idx+1:])) {
+			return errors.New("nullable column '" + s + "' is invalid")
+		}
+		byColumn[strings.TrimSpace(s[:idx])] = strings.TrimSpace(s[idx+1:])
+	}
+
+	for tidx := range tables {
+		table := &tables[tidx]
+		for idx := range table.Columns {
+			column := &table.Columns[idx]
+			if !column.IsNullable || column.IsPrimaryKey {
+				continue
+			}
+			if _, e := toNullValue(*column); nil != e {
+				continue
+			}
+			strategy, ok := byColumn[table.TableName+"."+column.DbName]
+			if !ok {
+				strategy = cmd.nullable
+			}
+			if strategy != nullZero {
+				column.NullStrategy = strategy
+			}
+		}
+	}
+
+	// 关系中保存的是列的拷贝
+	initRelations(tables)
+	return nil
+}
+
 func (cmd *GenerateModelsCommand) init() error {
 	var funcs = template.FuncMap{
+		"variable": func(name string) interface{} {
+			return cmd.variables[name]
+		},
+		"variables": func() map[string]interface{} {
+			return cmd.variables
+		},
 		"last": func(v interface{}, i int) (bool, error) {
 			rv := reflect.ValueOf(v)
 			if rv.Kind() != reflect.Slice {
@@ -67,14 +140,17 @@ func (cmd *GenerateModelsCommand) init() error {
 		"list_create": func(columns []Column) interface{} {
 			filterdColumns := make([]Column, 0, len(columns))
 			for _, column := range columns {
-				found := false
-				for _, s := range []string{"id"} {
-					if s == column.DbName {
-						found = true
-						break
-					}
+				// 自增和 identity 列的值由数据库生成
+				if !column.IsSequence {
+					filterdColumns = append(filterdColumns, column)
 				}
-				if !found {
+			}
+			return filterdColumns
+		},
+		"list_sequence": func(columns []Column) interface{} {
+			var filterdColumns []Column
+			for _, column := range columns {
+				if column.IsSequence {
 					filterdColumns = append(filterdColumns, column)
 				}
 			}
@@ -83,14 +159,7 @@ func (cmd *GenerateModelsCommand) init() error {
 		"list_update": func(columns []Column) interface{} {
 			filterdColumns := make([]Column, 0, len(columns))
 			for _, column := range columns {
-				found := false
-				for _, s := range []string{"id", "created_at"} {
-					if s == column.DbName {
-						found = true
-						break
-					}
-				}
-				if !found {
+				if !column.IsPrimaryKey && "created_at" != column.DbName {
 					filterdColumns = append(filterdColumns, column)
 				}
 			}
@@ -120,18 +189,27 @@ func (cmd *GenerateModelsCommand) init() error {
 			// }
 			return "null" + CamelCase(s)
 		},
-		"CamelCase":   CamelCase,
-		"Underscore":  Underscore,
-		"Pluralize":   Pluralize,
-		"Singularize": Singularize,
-		"Tableize":    Tableize,
-		"Capitalize":  Capitalize,
-		"Typeify":     Typeify,
-		"ToUpper":     strings.ToUpper,
-		"ToNullType":  toNullTypeFromPostgres,
-		"notSQLSupport": func(typ string) bool {
-			return "net.IP" == typ || "JSON" == typ
-		},
+		"CamelCase":     CamelCase,
+		"Underscore":    Underscore,
+		"Pluralize":     Pluralize,
+		"Singularize":   Singularize,
+		"Tableize":      Tableize,
+		"Capitalize":    Capitalize,
+		"Typeify":       Typeify,
+		"ToUpper":       strings.ToUpper,
+		"ToNullType":    toNullTypeFromPostgres,
+		"nullValue":     toNullValue,
+		"needNullValue": needNullValue,
+		"scanValue":     toScanValue,
+		"sqlValue":      toSQLValue,
+		"batchValue":    toBatchValue,
+		"fieldType":     toFieldType,
+		"isZero":        isZeroValue,
+		"notZero":       notZeroValue,
+		"setNow":        setNowValue,
+		"docComment":    docComment,
+		"finders":       toFinders,
+		"uniqueKeys":    toUniqueKeys,
 		"firstLower": func(s string) string {
 			if "" == s {
 				return s
@@ -140,7 +218,7 @@ func (cmd *GenerateModelsCommand) init() error {
 		},
 		"isIntegerType": func(s string) bool {
 			s = strings.ToLower(s)
-			return s == "int" || s == "int32" || s == "int64" ||
+			return s == "int" || s == "int16" || s == "int32" || s == "int64" ||
 				s == "uint" || s == "uint32" || s == "uint64"
 		},
 		//"ToNullValue": ToNullValueFromPostgres,
@@ -157,7 +235,7 @@ func (cmd *GenerateModelsCommand) init() error {
 		return e
 	}
 
-	cmd.templateModel.New("toNullValue").Parse(sqlNullValueText)
+	_, e = cmd.templateModel.New("toNullValue").Parse(sqlNullValueText)
 	if nil != e {
 		return e
 	}
@@ -166,6 +244,12 @@ func (cmd *GenerateModelsCommand) init() error {
 
 // Run - 生成数据库模型代码
 func (cmd *GenerateModelsCommand) Run(args []string) error {
+	cfg, e := loadConfig(cmd.flags, "models")
+	if nil != e {
+		return e
+	}
+	cmd.variables = cfg.variables("models")
+
 	if e := cmd.init(); nil != e {
 		return e
 	}
@@ -174,6 +258,12 @@ func (cmd *GenerateModelsCommand) Run(args []string) error {
 	if nil != e {
 		return e
 	}
+	if e := cmd.initNullable(tables); nil != e {
+		return e
+	}
+	if cmd.split {
+		return cmd.generateFiles(tables)
+	}
 
 	out := os.Stderr
 	switch strings.ToLower(cmd.file) {
@@ -189,13 +279,14 @@ func (cmd *GenerateModelsCommand) Run(args []string) error {
 			return e
 		}
 		target := filepath.Join(filepath.Dir(cmd.file), "base.go")
-		if e = copyFile(cmd.ns, embededText, target); nil != e {
+		if e = copyFile(cmd.ns, string(generator.DefaultTemplate("base")), target); nil != e {
 			return e
 		}
 	}
 
 	if e := cmd.templateHeader.Execute(out, map[string]interface{}{
 		"Namespace": cmd.ns,
+		"variables": cmd.variables,
 	}); nil != e {
 		return e
 	}
@@ -209,9 +300,55 @@ func (cmd *GenerateModelsCommand) Run(args []string) error {
 	return nil
 }
 
+// generateFiles 为每个表生成一个文件, 文件名为类名的下划线形式, 如 book_tag.go
+func (cmd *GenerateModelsCommand) generateFiles(tables []Table) error {
+	switch strings.ToLower(cmd.file) {
+	case "stdout", "stderr", "":
+		return errors.New("-split requires -file is a file in the output directory")
+	}
+
+	dir := filepath.Dir(cmd.file)
+	if e := os.MkdirAll(dir, 0755); nil != e {
+		return e
+	}
+	if e := copyFile(cmd.ns, string(generator.DefaultTemplate("base")), filepath.Join(dir, "base.go")); nil != e {
+		return e
+	}
+
+	for _, table := range tables {
+		name := Underscore(table.ClassName)
+		if "base" == name {
+			name = "base_model"
+		}
+		filename := filepath.Join(dir, name+".go")
+		log.Println("GEN ", table.TableName, "to", filename)
+		if e := cmd.generateFile(filename, table); nil != e {
+			return e
+		}
+	}
+	return nil
+}
+
+func (cmd *GenerateModelsCommand) generateFile(filename string, table Table) error {
+	out, e := os.OpenFile(filename, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0666)
+	if nil != e {
+		return e
+	}
+	defer out.Close()
+
+	if e := cmd.templateHeader.Execute(out, map[string]interface{}{
+		"Namespace": cmd.ns,
+		"variables": cmd.variables,
+	}); nil != e {
+		return e
+	}
+	return cmd.genrateFromTable(out, table)
+}
+
 func (cmd *GenerateModelsCommand) genrateFromTable(out io.Writer, table Table) error {
 	return cmd.templateModel.Execute(out, map[string]interface{}{
 		"Namespace": cmd.ns,
+		"variables": cmd.variables,
 		"table":     table,
 		"columns":   table.Columns,
 	})
@@ -259,19 +396,24 @@ var modelsheaderText = `// file is generated by gengen
 package {{.Namespace}}
 
 import (
+  "context"
   "github.com/Masterminds/squirrel"
   "time"
   "net"
   "database/sql"
   "github.com/lib/pq"
+  "github.com/lib/pq/hstore"
   "errors"
 )
 
 `
 
-var modelText = `type {{.table.ClassName}} struct { {{range $x := .columns }}{{if eq "Id" $x.GoName}}
-  {{$x.GoName}} int64` + "\t`json:\"id,omitempty\"`" + `
-  {{else}}{{$x.GoName}} {{$x.GoType}}` + "\t`json:\"{{$x.DbName}},omitempty\"`" + `{{end}}
+var modelText = `{{if .table.Comment}}{{docComment .table.ClassName .table.Comment}}
+{{end}}type {{.table.ClassName}} struct { {{range $x := .columns }}{{if eq "Id" $x.GoName}}
+  {{if $x.Comment}}{{docComment "" $x.Comment}}
+  {{end}}{{$x.GoName}} {{fieldType $x}}` + "\t`json:\"id,omitempty\"`" + `
+  {{else}}{{if $x.Comment}}{{docComment "" $x.Comment}}
+  {{end}}{{$x.GoName}} {{fieldType $x}}` + "\t`json:\"{{$x.DbName}},omitempty\"`" + `{{end}}
 {{end}}
 }
 
@@ -283,13 +425,27 @@ func (self *{{.table.ClassName}}) CreateIt(db squirrel.BaseRunner) error { {{end
   return {{.table.ClassName}}Model.CreateIt(db, self)
 }
 
+{{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}
+func (self *{{.table.ClassName}}) CreateItContext(ctx context.Context, db BaseRunnerContext) ({{$pk.GoType}}, error){ {{else}}
+func (self *{{.table.ClassName}}) CreateItContext(ctx context.Context, db BaseRunnerContext) error { {{end}}
+  return {{.table.ClassName}}Model.CreateItContext(ctx, db, self)
+}
+
 func (self *{{.table.ClassName}}) UpdateIt(db squirrel.BaseRunner) error {
   return {{.table.ClassName}}Model.UpdateIt(db, self)
 }
 
+func (self *{{.table.ClassName}}) UpdateItContext(ctx context.Context, db BaseRunnerContext) error {
+  return {{.table.ClassName}}Model.UpdateItContext(ctx, db, self)
+}
+
 func (self *{{.table.ClassName}}) DeleteIt(db squirrel.BaseRunner) error { 
   return {{.table.ClassName}}Model.DeleteIt(db, self)
 }
+
+func (self *{{.table.ClassName}}) DeleteItContext(ctx context.Context, db BaseRunnerContext) error {
+  return {{.table.ClassName}}Model.DeleteItContext(ctx, db, self)
+}
 {{end}}
 
 type {{firstLower .table.ClassName}}Columns struct{
@@ -304,19 +460,16 @@ type {{firstLower .table.ClassName}}Model struct{
 
 func (self *{{firstLower .table.ClassName}}Model) scan(scanner squirrel.RowScanner) (*{{.table.ClassName}}, error){
   var value {{.table.ClassName}}
-  {{$columns := .columns}}{{range $x := .columns }}{{if $x.IsNullable}}var {{toNullName $x.DbName}} {{ToNullType $x.DbType}}
-  {{else if eq "net.IP" $x.GoType}}var {{toNullName $x.DbName}} sql.NullString
+  {{$columns := .columns}}{{range $x := .columns }}{{if needNullValue $x}}var {{toNullName $x.DbName}} {{ToNullType $x}}
   {{end}}{{end}}
 
-  e := scanner.Scan({{range $idx, $x := .columns }}{{if eq "net.IP" $x.GoType}}&{{toNullName $x.DbName}}{{else if $x.IsNullable}}&{{toNullName $x.DbName}}{{else}}&value.{{$x.GoName}}{{end}}{{if last $columns $idx | not}},
+  e := scanner.Scan({{range $idx, $x := .columns }}{{scanValue $x}}{{if last $columns $idx | not}},
     {{end}}{{end}})
   if nil != e {
     return nil, e
   }
 
-  {{range $x := .columns }}{{if $x.IsNullable}}
-  {{template "toNullValue" $x}}
-  {{else if  eq "net.IP" $x.GoType}}
+  {{range $x := .columns }}{{if needNullValue $x}}
   {{template "toNullValue" $x}}
   {{end}}{{end}}
 
@@ -324,18 +477,22 @@ func (self *{{firstLower .table.ClassName}}Model) scan(scanner squirrel.RowScann
 }
 
 func (self *{{firstLower .table.ClassName}}Model) QueryRowWith(db squirrel.QueryRower, builder squirrel.SelectBuilder) (*{{.table.ClassName}}, error){
-  if isPlaceholderWithDollar(db) {
-    builder = builder.PlaceholderFormat(squirrel.Dollar)
-  }
-  return self.scan(squirrel.QueryRowWith(db, builder.Columns(self.ColumnNames...).From(self.TableName)))
+  return self.QueryRowWithContext(context.Background(), toRunner(db), builder)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) QueryRowWithContext(ctx context.Context, db squirrel.QueryRowerContext, builder squirrel.SelectBuilder) (*{{.table.ClassName}}, error){
+  builder = builder.PlaceholderFormat(self.DialectOf(db).PlaceholderFormat())
+  return self.scan(squirrel.QueryRowContextWith(ctx, db, builder.Columns(self.ColumnNames...).From(self.TableName)))
 }
 
 func (self *{{firstLower .table.ClassName}}Model) QueryWith(db squirrel.Queryer, builder squirrel.SelectBuilder) ([]*{{.table.ClassName}}, error){
-  if isPlaceholderWithDollar(db) {
-    builder = builder.PlaceholderFormat(squirrel.Dollar)
-  }
+  return self.QueryWithContext(context.Background(), toRunner(db), builder)
+}
 
-  rows, e := squirrel.QueryWith(db, builder.Columns(self.ColumnNames...).From(self.TableName))
+func (self *{{firstLower .table.ClassName}}Model) QueryWithContext(ctx context.Context, db squirrel.QueryerContext, builder squirrel.SelectBuilder) ([]*{{.table.ClassName}}, error){
+  builder = builder.PlaceholderFormat(self.DialectOf(db).PlaceholderFormat())
+
+  rows, e := squirrel.QueryContextWith(ctx, db, builder.Columns(self.ColumnNames...).From(self.TableName))
   if nil != e {
     return nil, e
   }
@@ -352,47 +509,182 @@ func (self *{{firstLower .table.ClassName}}Model) QueryWith(db squirrel.Queryer,
   return results, rows.Err()
 }
 
-{{if not .table.IsCombinedKey}}
-func (self *{{firstLower .table.ClassName}}Model) FindByID(db squirrel.QueryRower, id int64) (*{{.table.ClassName}}, error){
-  builder := squirrel.Select().From(self.TableName).Where(squirrel.Eq{"id": id})
-  return self.QueryRowWith(db, builder)
+// {{firstLower .table.ClassName}}Query 带类型的查询对象, 见 Query
+type {{firstLower .table.ClassName}}Query struct {
+  *Query
+  model *{{firstLower .table.ClassName}}Model
+}
+
+func (self *{{firstLower .table.ClassName}}Model) Query(exprs ...Sqlizer) *{{firstLower .table.ClassName}}Query {
+  return &{{firstLower .table.ClassName}}Query{Query: self.{{if .table.IsView}}ViewModel{{else}}DbModel{{end}}.Query(exprs...), model: self}
+}
+
+func (q *{{firstLower .table.ClassName}}Query) Where(exprs ...Sqlizer) *{{firstLower .table.ClassName}}Query {
+  q.Query.Where(exprs...)
+  return q
+}
+
+func (q *{{firstLower .table.ClassName}}Query) OrderBy(column ColumnModel, dir Direction) *{{firstLower .table.ClassName}}Query {
+  q.Query.OrderBy(column, dir)
+  return q
+}
+
+func (q *{{firstLower .table.ClassName}}Query) Limit(limit uint64) *{{firstLower .table.ClassName}}Query {
+  q.Query.Limit(limit)
+  return q
+}
+
+func (q *{{firstLower .table.ClassName}}Query) Offset(offset uint64) *{{firstLower .table.ClassName}}Query {
+  q.Query.Offset(offset)
+  return q
+}
+
+func (q *{{firstLower .table.ClassName}}Query) After(values ...interface{}) *{{firstLower .table.ClassName}}Query {
+  q.Query.After(values...)
+  return q
+}
+
+func (q *{{firstLower .table.ClassName}}Query) All(db squirrel.Queryer) ([]*{{.table.ClassName}}, error) {
+  return q.AllContext(context.Background(), toRunner(db))
+}
+
+func (q *{{firstLower .table.ClassName}}Query) AllContext(ctx context.Context, db squirrel.QueryerContext) ([]*{{.table.ClassName}}, error) {
+  builder, e := q.ToSelect(q.model.DialectOf(db))
+  if nil != e {
+    return nil, e
+  }
+  return q.model.QueryWithContext(ctx, db, builder)
+}
+
+func (q *{{firstLower .table.ClassName}}Query) One(db squirrel.QueryRower) (*{{.table.ClassName}}, error) {
+  return q.OneContext(context.Background(), toRunner(db))
+}
+
+func (q *{{firstLower .table.ClassName}}Query) OneContext(ctx context.Context, db squirrel.QueryRowerContext) (*{{.table.ClassName}}, error) {
+  builder, e := q.ToSelect(q.model.DialectOf(db))
+  if nil != e {
+    return nil, e
+  }
+  return q.model.QueryRowWithContext(ctx, db, builder.Limit(1))
+}
+
+func (q *{{firstLower .table.ClassName}}Query) Page(db squirrel.Queryer, page, size uint64) ([]*{{.table.ClassName}}, int64, error) {
+  return q.PageContext(context.Background(), toRunner(db), page, size)
+}
+
+// PageContext 返回第 page 页 (从 1 开始) 的记录和符合条件的记录总数
+func (q *{{firstLower .table.ClassName}}Query) PageContext(ctx context.Context, db squirrel.QueryerContext, page, size uint64) ([]*{{.table.ClassName}}, int64, error) {
+  runner := toRunner(db)
+  total, e := q.CountContext(ctx, runner)
+  if nil != e {
+    return nil, 0, e
+  }
+  paged := &{{firstLower .table.ClassName}}Query{Query: q.Paginate(page, size), model: q.model}
+  values, e := paged.AllContext(ctx, runner)
+  if nil != e {
+    return nil, 0, e
+  }
+  return values, total, nil
+}
+
+{{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}
+func (self *{{firstLower .table.ClassName}}Model) FindByID(db squirrel.QueryRower, id {{$pk.GoType}}) (*{{.table.ClassName}}, error){
+  return self.FindByIDContext(context.Background(), toRunner(db), id)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) FindByIDContext(ctx context.Context, db squirrel.QueryRowerContext, id {{$pk.GoType}}) (*{{.table.ClassName}}, error){
+  builder := squirrel.Select().From(self.TableName).Where(squirrel.Eq{"{{$pk.DbName}}": id})
+  return self.QueryRowWithContext(ctx, db, builder)
 }
 {{else if not .table.IsView}}
 func (self *{{firstLower .table.ClassName}}Model) FindByKey(db squirrel.QueryRower, {{range $idx, $column := .table.PrimaryKey}}{{firstLower $column.GoName}} {{$column.GoType}}{{if last $columns $idx | not}},
       {{end}}{{end}}) (*{{.table.ClassName}}, error){
+  return self.FindByKeyContext(context.Background(), toRunner(db), {{range $idx, $column := .table.PrimaryKey}}{{firstLower $column.GoName}}{{if last $columns $idx | not}}, {{end}}{{end}})
+}
+
+func (self *{{firstLower .table.ClassName}}Model) FindByKeyContext(ctx context.Context, db squirrel.QueryRowerContext, {{range $idx, $column := .table.PrimaryKey}}{{firstLower $column.GoName}} {{$column.GoType}}{{if last $columns $idx | not}},
+      {{end}}{{end}}) (*{{.table.ClassName}}, error){
   builder := squirrel.Select().From(self.TableName).Where({{range $idx, $column := .table.PrimaryKey}}squirrel.Eq{"{{$column.DbName}}": {{firstLower $column.GoName}} }{{if last $columns $idx | not}},
       {{end}}{{end}})
-  return self.QueryRowWith(db, builder)
+  return self.QueryRowWithContext(ctx, db, builder)
 }
 {{end}}
+{{range $r := .table.BelongsTo}}
+// Load{{$r.Name}} 读取 {{$r.Column.DbName}} 引用的 {{$r.TargetClass}}
+func (self *{{firstLower $r.ClassName}}Model) Load{{$r.Name}}(db squirrel.QueryRower, value *{{$r.ClassName}}) (*{{$r.TargetClass}}, error){
+  return self.Load{{$r.Name}}Context(context.Background(), toRunner(db), value)
+}
+
+func (self *{{firstLower $r.ClassName}}Model) Load{{$r.Name}}Context(ctx context.Context, db squirrel.QueryRowerContext, value *{{$r.ClassName}}) (*{{$r.TargetClass}}, error){
+  {{if and $r.Column.IsNullable (isIntegerType $r.Column.GoType)}}if {{isZero $r.Column}} {
+    return nil, nil
+  }
+  {{end}}builder := squirrel.Select().From({{$r.TargetClass}}Model.TableName).Where(squirrel.Eq{"{{$r.TargetColumn.DbName}}": value.{{$r.Column.GoName}} })
+  return {{$r.TargetClass}}Model.QueryRowWithContext(ctx, db, builder)
+}
+{{end}}{{range $r := .table.HasMany}}
+// {{$r.Name}}By{{$r.Column.GoName}} 读取 {{$r.Column.DbName}} 引用了 key 的所有 {{$r.ClassName}}
+func (self *{{firstLower $r.TargetClass}}Model) {{$r.Name}}By{{$r.Column.GoName}}(db squirrel.Queryer, key {{$r.Column.GoType}}) ([]*{{$r.ClassName}}, error){
+  return self.{{$r.Name}}By{{$r.Column.GoName}}Context(context.Background(), toRunner(db), key)
+}
 
+func (self *{{firstLower $r.TargetClass}}Model) {{$r.Name}}By{{$r.Column.GoName}}Context(ctx context.Context, db squirrel.QueryerContext, key {{$r.Column.GoType}}) ([]*{{$r.ClassName}}, error){
+  builder := squirrel.Select().From({{$r.ClassName}}Model.TableName).Where(squirrel.Eq{"{{$r.Column.DbName}}": key})
+  return {{$r.ClassName}}Model.QueryWithContext(ctx, db, builder)
+}
+{{end}}{{range $f := finders .table}}
+{{if $f.IsUnique}}// {{$f.Name}} 按唯一索引 {{$f.Index}} 读取一条记录
+func (self *{{firstLower $.table.ClassName}}Model) {{$f.Name}}(db squirrel.QueryRower, {{range $idx, $column := $f.Columns}}{{if $idx}}, {{end}}{{index $f.Params $idx}} {{$column.GoType}}{{end}}) (*{{$.table.ClassName}}, error){
+  return self.{{$f.Name}}Context(context.Background(), toRunner(db){{range $idx, $column := $f.Columns}}, {{index $f.Params $idx}}{{end}})
+}
+
+func (self *{{firstLower $.table.ClassName}}Model) {{$f.Name}}Context(ctx context.Context, db squirrel.QueryRowerContext, {{range $idx, $column := $f.Columns}}{{if $idx}}, {{end}}{{index $f.Params $idx}} {{$column.GoType}}{{end}}) (*{{$.table.ClassName}}, error){
+  builder := squirrel.Select().From(self.TableName).Where(squirrel.Eq{ {{range $idx, $column := $f.Columns}}"{{$column.DbName}}": {{index $f.Params $idx}}, {{end}} })
+  return self.QueryRowWithContext(ctx, db, builder)
+}
+{{else}}// {{$f.Name}} 按索引 {{$f.Index}} 读取所有的记录
+func (self *{{firstLower $.table.ClassName}}Model) {{$f.Name}}(db squirrel.Queryer, {{range $idx, $column := $f.Columns}}{{if $idx}}, {{end}}{{index $f.Params $idx}} {{$column.GoType}}{{end}}) ([]*{{$.table.ClassName}}, error){
+  return self.{{$f.Name}}Context(context.Background(), toRunner(db){{range $idx, $column := $f.Columns}}, {{index $f.Params $idx}}{{end}})
+}
+
+func (self *{{firstLower $.table.ClassName}}Model) {{$f.Name}}Context(ctx context.Context, db squirrel.QueryerContext, {{range $idx, $column := $f.Columns}}{{if $idx}}, {{end}}{{index $f.Params $idx}} {{$column.GoType}}{{end}}) ([]*{{$.table.ClassName}}, error){
+  builder := squirrel.Select().From(self.TableName).Where(squirrel.Eq{ {{range $idx, $column := $f.Columns}}"{{$column.DbName}}": {{index $f.Params $idx}}, {{end}} })
+  return self.QueryWithContext(ctx, db, builder)
+}
+{{end}}{{end}}
 {{if not .table.IsView }}
 {{$oldValues := .}}
 {{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}
 func (self *{{firstLower .table.ClassName}}Model) CreateIt(db squirrel.BaseRunner, value *{{.table.ClassName}}) ({{$pk.GoType}}, error){ {{else}}
 func (self *{{firstLower .table.ClassName}}Model) CreateIt(db squirrel.BaseRunner, value *{{.table.ClassName}}) error { {{end}}
-    {{if .table.HasCreatedAt}}value.CreatedAt = time.Now()
-    {{end}}{{if .table.HasUpdatedAt}}value.UpdatedAt = time.Now()
+  return self.CreateItContext(context.Background(), toRunner(db), value)
+}
+
+{{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}
+func (self *{{firstLower .table.ClassName}}Model) CreateItContext(ctx context.Context, db BaseRunnerContext, value *{{.table.ClassName}}) ({{$pk.GoType}}, error){ {{else}}
+func (self *{{firstLower .table.ClassName}}Model) CreateItContext(ctx context.Context, db BaseRunnerContext, value *{{.table.ClassName}}) error { {{end}}
+    {{if .table.HasCreatedAt}}{{setNow .table "created_at"}}
+    {{end}}{{if .table.HasUpdatedAt}}{{setNow .table "updated_at"}}
     {{end}}{{$columns := .columns | list_create}}{{$fkeyCount := columns_count_foreign_keys $columns}}{{if eq $fkeyCount 0 }}builder := squirrel.Insert(self.TableName).Columns({{list_join $columns}}).
-    Values({{range $idx, $x := $columns }}value.{{$x.GoName}}{{if notSQLSupport $x.GoType}}.String(){{end}}{{if last $columns $idx | not}},
+    Values({{range $idx, $x := $columns }}{{sqlValue $x}}{{if last $columns $idx | not}},
     {{end}}{{end}})
   {{else if eq $fkeyCount 1}}{{range $idx, $x := $columns }}{{if $x.IsForeignKey}}{{set $oldValues "foreignKey" $x}}{{end}}{{end}}var builder squirrel.InsertBuilder
 
-  if value.{{.foreignKey.GoName}} <= 0 {
+  if {{isZero .foreignKey}} {
     builder = squirrel.Insert(self.TableName).Columns({{columns_remove_foreign_keys $columns | list_join}}).
-      Values({{range $idx, $x := columns_remove_foreign_keys $columns }}value.{{$x.GoName}}{{if notSQLSupport $x.GoType}}.String(){{end}}{{if last $columns $idx | not}},
+      Values({{range $idx, $x := columns_remove_foreign_keys $columns }}{{sqlValue $x}}{{if last $columns $idx | not}},
       {{end}}{{end}})
   } else {
     builder = squirrel.Insert(self.TableName).Columns({{list_join $columns}}).
-      Values({{range $idx, $x := $columns }}value.{{$x.GoName}}{{if notSQLSupport $x.GoType}}.String(){{end}}{{if last $columns $idx | not}},
+      Values({{range $idx, $x := $columns }}{{sqlValue $x}}{{if last $columns $idx | not}},
       {{end}}{{end}})
   }
   {{else}} {{/*  if eq $fkeyCount 1 */}}
   columnNames := []string{ {{columns_remove_foreign_keys $columns | list_join}} }
-  columnValues := []interface{}{ {{range $idx, $x := columns_remove_foreign_keys $columns }}value.{{$x.GoName}}{{if notSQLSupport $x.GoType}}.String(){{end}}{{if last $columns $idx | not}},
+  columnValues := []interface{}{ {{range $idx, $x := columns_remove_foreign_keys $columns }}{{sqlValue $x}}{{if last $columns $idx | not}},
       {{end}}{{end}}}
 
-  {{range $idx, $x := $columns }}{{if $x.IsForeignKey}}if value.{{$x.GoName}} > 0 {
+  {{range $idx, $x := $columns }}{{if $x.IsForeignKey}}if {{notZero $x}} {
     columnNames = append(columnNames, "{{$x.DbName}}")
     columnValues = append(columnValues, value.{{$x.GoName}})
   }
@@ -401,12 +693,10 @@ func (self *{{firstLower .table.ClassName}}Model) CreateIt(db squirrel.BaseRunne
   var  builder = squirrel.Insert(self.TableName).Columns(columnNames...).
       Values(columnValues...)
   {{end}} {{/*  if eq $fkeyCount 1 */}}
-  if isPlaceholderWithDollar(db) {
-    builder = builder.PlaceholderFormat(squirrel.Dollar)
-  }
+  builder = builder.PlaceholderFormat(self.DialectOf(db).PlaceholderFormat())
 
 {{if .table.IsCombinedKey}}
-  result, e := builder.RunWith(db).Exec();
+  result, e := builder.RunWith(db).ExecContext(ctx)
   if nil != e {
     return e
   }
@@ -416,16 +706,16 @@ func (self *{{firstLower .table.ClassName}}Model) CreateIt(db squirrel.BaseRunne
 {{else}}
 {{$pk := index .table.PrimaryKey 0}}
 {{if $pk.IsSequence}}
-  if isPostgersql(db) {
-    if e := builder.Suffix("RETURNING \"{{$pk.DbName}}\"").RunWith(db).
-        QueryRow().Scan(&value.{{$pk.GoName}}); nil != e {
+  if dialect := self.DialectOf(db); dialect.HasReturning() {
+    if e := builder.Suffix("RETURNING " + dialect.Quote("{{$pk.DbName}}")).RunWith(db).
+        QueryRowContext(ctx).Scan(&value.{{$pk.GoName}}); nil != e {
       return 0, e
     }
 
     return value.{{$pk.GoName}}, nil
   }
 
-  result, e := builder.RunWith(db).Exec();
+  result, e := builder.RunWith(db).ExecContext(ctx)
   if nil != e {
     return value.{{$pk.GoName}}, e
   }
@@ -438,7 +728,7 @@ func (self *{{firstLower .table.ClassName}}Model) CreateIt(db squirrel.BaseRunne
   return value.{{$pk.GoName}}, e
 }
 {{else}} {{/* IsSequence */}}
-  result, e := builder.RunWith(db).Exec();
+  result, e := builder.RunWith(db).ExecContext(ctx)
   if nil != e {
     return value.{{$pk.GoName}}, e
   }
@@ -451,6 +741,10 @@ func (self *{{firstLower .table.ClassName}}Model) CreateIt(db squirrel.BaseRunne
 
 {{$columns := .columns}}
 func (self *{{firstLower .table.ClassName}}Model) UpdateIt(db squirrel.BaseRunner, value *{{.table.ClassName}}) (error) {
+  return self.UpdateItContext(context.Background(), toRunner(db), value)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) UpdateItContext(ctx context.Context, db BaseRunnerContext, value *{{.table.ClassName}}) (error) {
   {{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}{{if isIntegerType $pk.GoType}}if 0 == value.{{$pk.GoName}} {
     return ThrowUpdateFailWithPrimaryKeyInvalid(self.TableName)
   }
@@ -459,22 +753,20 @@ func (self *{{firstLower .table.ClassName}}Model) UpdateIt(db squirrel.BaseRunne
     return ThrowUpdateFailWithPrimaryKeyInvalid(self.TableName)
   }
 
-  {{end}}{{end}}{{if .table.HasUpdatedAt}}value.UpdatedAt = time.Now()
+  {{end}}{{end}}{{if .table.HasUpdatedAt}}{{setNow .table "updated_at"}}
   {{end}}{{$columns := .columns | list_update}}builder := squirrel.Update(self.TableName).
-    {{range $idx, $x := $columns }}{{if not $x.IsForeignKey}}{{if not $x.IsPrimaryKey}}Set("{{$x.DbName}}", value.{{$x.GoName}}{{if notSQLSupport $x.GoType}}.String(){{end}}).
+    {{range $idx, $x := $columns }}{{if not $x.IsForeignKey}}{{if not $x.IsPrimaryKey}}Set("{{$x.DbName}}", {{sqlValue $x}}).
     {{end}}{{end}}{{end}}Where({{range $idx, $column := .table.PrimaryKey}}squirrel.Eq{"{{$column.DbName}}": value.{{$column.GoName}} }{{if last $columns $idx | not}},
       {{end}}{{end}})
 
-  {{range $idx, $x := $columns }}{{if $x.IsForeignKey}}if value.{{$x.GoName}} > 0 {
+  {{range $idx, $x := $columns }}{{if $x.IsForeignKey}}if {{notZero $x}} {
     builder = builder.Set("{{$x.DbName}}", value.{{$x.GoName}})
   }
   {{end}}{{end}}
 
-  if isPlaceholderWithDollar(db) {
-    builder = builder.PlaceholderFormat(squirrel.Dollar)
-  }
+  builder = builder.PlaceholderFormat(self.DialectOf(db).PlaceholderFormat())
 
-  result, e := builder.RunWith(db).Exec();
+  result, e := builder.RunWith(db).ExecContext(ctx)
   if nil != e {
     return e
   }
@@ -490,8 +782,12 @@ func (self *{{firstLower .table.ClassName}}Model) UpdateIt(db squirrel.BaseRunne
 
 
 func (self *{{firstLower .table.ClassName}}Model) DeleteIt(db squirrel.BaseRunner, value *{{.table.ClassName}}) error {
-  {{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}return self.DeleteByID(db, value.{{$pk.GoName}})
-  {{else}}count, err := self.DeleteBy(db, {{range $idx, $column := .table.PrimaryKey}}squirrel.Eq{"{{$column.DbName}}": value.{{$column.GoName}} }{{if last $columns $idx | not}},
+  return self.DeleteItContext(context.Background(), toRunner(db), value)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) DeleteItContext(ctx context.Context, db BaseRunnerContext, value *{{.table.ClassName}}) error {
+  {{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}return self.DeleteByIDContext(ctx, db, value.{{$pk.GoName}})
+  {{else}}count, err := self.DeleteByContext(ctx, db, {{range $idx, $column := .table.PrimaryKey}}squirrel.Eq{"{{$column.DbName}}": value.{{$column.GoName}} }{{if last $columns $idx | not}},
       {{end}}{{end}})
   if err != nil {
     return err
@@ -504,6 +800,10 @@ func (self *{{firstLower .table.ClassName}}Model) DeleteIt(db squirrel.BaseRunne
 
 {{if not .table.IsCombinedKey}}{{$pk := index .table.PrimaryKey 0}}
 func (self *{{firstLower .table.ClassName}}Model) DeleteByID(db squirrel.BaseRunner, key {{$pk.GoType}}) error {
+  return self.DeleteByIDContext(context.Background(), toRunner(db), key)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) DeleteByIDContext(ctx context.Context, db BaseRunnerContext, key {{$pk.GoType}}) error {
   {{if isIntegerType $pk.GoType}}if 0 == key {
     return ThrowDeleteFailWithPrimaryKeyInvalid(self.TableName)
   }
@@ -512,7 +812,7 @@ func (self *{{firstLower .table.ClassName}}Model) DeleteByID(db squirrel.BaseRun
   }
   {{end}}
 
-  count, err := self.DeleteBy(db, squirrel.Eq{"{{$pk.DbName}}": key})
+  count, err := self.DeleteByContext(ctx, db, squirrel.Eq{"{{$pk.DbName}}": key})
   if err != nil {
     return err
   }
@@ -522,6 +822,47 @@ func (self *{{firstLower .table.ClassName}}Model) DeleteByID(db squirrel.BaseRun
   return nil
 }
 {{end}}
+
+func (self *{{firstLower .table.ClassName}}Model) InsertMany(db squirrel.BaseRunner, values []*{{.table.ClassName}}) (int64, error) {
+  return self.InsertManyContext(context.Background(), toRunner(db), values)
+}
+
+// InsertManyContext 批量插入记录, 自增的列由数据库生成, 它们的值不会取回
+func (self *{{firstLower .table.ClassName}}Model) InsertManyContext(ctx context.Context, db BaseRunnerContext, values []*{{.table.ClassName}}) (int64, error) {
+  columns, rows := self.batchRows(values, false)
+  return self.DbModel.InsertManyContext(ctx, db, columns, rows)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) Upsert(db squirrel.BaseRunner, key string, values []*{{.table.ClassName}}) (int64, error) {
+  return self.UpsertContext(context.Background(), toRunner(db), key, values)
+}
+
+// UpsertContext 批量插入或更新记录, key 为空时按主键判断冲突, 这时自增的列也会写入,
+// 否则按名为 key 的唯一索引判断冲突
+func (self *{{firstLower .table.ClassName}}Model) UpsertContext(ctx context.Context, db BaseRunnerContext, key string, values []*{{.table.ClassName}}) (int64, error) {
+  columns, rows := self.batchRows(values, "" == key)
+  return self.DbModel.UpsertContext(ctx, db, key, columns, rows)
+}
+
+func (self *{{firstLower .table.ClassName}}Model) batchRows(values []*{{.table.ClassName}}, withSequence bool) ([]string, [][]interface{}) {
+  {{$columns := .columns | list_create}}{{$sequences := .columns | list_sequence}}columns := []string{ {{list_join $columns}} }
+  {{if $sequences}}if withSequence {
+    columns = append([]string{ {{list_join $sequences}} }, columns...)
+  }
+  {{end}}
+  rows := make([][]interface{}, 0, len(values))
+  for _, value := range values {
+    {{if .table.HasCreatedAt}}{{setNow .table "created_at"}}
+    {{end}}{{if .table.HasUpdatedAt}}{{setNow .table "updated_at"}}
+    {{end}}row := []interface{}{ {{range $idx, $x := $columns }}{{batchValue $x}}{{if last $columns $idx | not}},
+      {{end}}{{end}}}
+    {{if $sequences}}if withSequence {
+      row = append([]interface{}{ {{range $idx, $x := $sequences }}{{if $idx}}, {{end}}{{sqlValue $x}}{{end}} }, row...)
+    }
+    {{end}}rows = append(rows, row)
+  }
+  return columns, rows
+}
 {{end}} {{/* isView end */}}
 
 var {{.table.ClassName}}Model = {{firstLower .table.ClassName}}Model{
@@ -531,49 +872,19 @@ var {{.table.ClassName}}Model = {{firstLower .table.ClassName}}Model{
   ColumnNames: []string{ {{range $x := .columns }}  "{{$x.DbName}}", 
   {{end}}}},
   KeyNames: []string{ {{range $x := .columns }} {{if $x.IsPrimaryKey }}"{{$x.DbName}}", 
-  {{end}}{{end}} },
+  {{end}}{{end}} },{{with uniqueKeys .table}}
+  UniqueKeys: map[string][]string{ {{range $index := .}}
+    "{{$index.Name}}": { {{range $name := $index.Columns}}"{{$name}}", {{end}} },{{end}}
+  },{{end}}
   },{{end}}
   C: {{firstLower .table.ClassName}}Columns{ {{range $x := .columns }}{{ToUpper $x.GoName}}: ColumnModel{"{{$x.DbName}}"},
     {{end}}},
 }
 `
 
-var sqlNullValueText = `{{if eq .DbType "bool"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Bool
-    }{{else if eq .GoType "int64"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Int64
-    }{{else if eq .DbType "int4"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = int({{toNullName .DbName}}.Int64)
-    }{{else if eq .DbType "int8"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Int64
-    }{{else if eq .DbType "float4"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Float64
-    }{{else if eq .DbType "float8"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Float64
-    }{{else if eq .DbType "numeric"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Float64
-    }{{else if eq .DbType "varchar"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.String
-    }{{else if eq .DbType "text"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.String
-    }{{else if eq .DbType "json"}}
-      value.{{.GoName}} = ToJSON({{toNullName .DbName}})
-    {{else if eq .DbType "jsonb"}}
+var sqlNullValueText = `{{if eq .GoType "JSON"}}
       value.{{.GoName}} = ToJSON({{toNullName .DbName}})
-    {{else if eq .DbType "timestamp"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Time
-    }{{else if eq .DbType "timestamptz"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.Time
-    }{{else if eq .GoType "net.IP"}}if {{toNullName .DbName}}.Valid { 
-      if "" != {{toNullName .DbName}}.String {
-        ipValue := net.ParseIP({{toNullName .DbName}}.String)
-        if nil != ipValue {
-          value.{{.GoName}} = ipValue
-        } else if cidr, _, e := net.ParseCIDR({{toNullName .DbName}}.String); nil == e {
-          value.{{.GoName}} = cidr
-        }
-      }
-    }{{else if eq .DbType "cidr"}}if {{toNullName .DbName}}.Valid { 
+    {{else if eq .GoType "net.IP"}}if {{toNullName .DbName}}.Valid { 
       if "" != {{toNullName .DbName}}.String {
         ipValue := net.ParseIP({{toNullName .DbName}}.String)
         if nil != ipValue {
@@ -582,62 +893,299 @@ var sqlNullValueText = `{{if eq .DbType "bool"}}if {{toNullName .DbName}}.Valid
           value.{{.GoName}} = cidr
         }
       }
-    }{{else if eq .DbType "macaddr"}}if {{toNullName .DbName}}.Valid { 
-      value.{{.GoName}} = {{toNullName .DbName}}.String
     }{{else}}if {{toNullName .DbName}}.Valid { 
-      type({{.DbType}}) of value.{{.DbName}} is unsupported...........................................
+      value.{{.GoName}} = {{nullValue .}}
     }{{end}}`
 
-func toGoTypeFromDbType(tableName, nm string) string {
+// toGoTypeFromDbType 返回 PostgreSQL 的 udt_name 对应的 go 类型, 以 _ 开头的
+// 是数组, 如 _int4, _text
+func toGoTypeFromDbType(tableName, nm string) (string, error) {
 	switch nm {
 	case "bool":
-		return "bool"
+		return "bool", nil
+	case "int2":
+		return "int16", nil
 	case "int4":
-		return "int"
+		return "int", nil
 	case "int8":
-		return "int64"
+		return "int64", nil
 	case "float4":
-		return "float"
+		return "float32", nil
 	case "float8", "numeric":
-		return "float64"
-	case "varchar", "text":
-		return "string"
-	case "timestamp", "timestamptz":
-		return "time.Time"
-	case "cidr":
-		return "net.IP"
+		return "float64", nil
+	case "varchar", "text", "bpchar", "char", "uuid", "interval", "xml", "citext", "enum":
+		return "string", nil
+	case "timestamp", "timestamptz", "date", "time", "timetz":
+		return "time.Time", nil
+	case "cidr", "inet":
+		return "net.IP", nil
 	case "macaddr":
-		return "string"
+		return "string", nil
 	case "json", "jsonb":
-		return "JSON"
+		return "JSON", nil
+	case "bytea":
+		return "[]byte", nil
+	case "hstore":
+		return "hstore.Hstore", nil
+	case "_int2", "_int4", "_int8":
+		return "[]int64", nil
+	case "_float4", "_float8", "_numeric":
+		return "[]float64", nil
+	case "_varchar", "_text", "_bpchar", "_uuid":
+		return "[]string", nil
+	case "_bool":
+		return "[]bool", nil
 	default:
-		panic("'" + nm + "' of '" + tableName + "' is unsupported")
+		return "", errors.New("'" + nm + "' of '" + tableName + "' is unsupported")
 	}
 }
 
-func toNullTypeFromPostgres(nm string) string {
-	switch nm {
+// isScanDirect 判断列是否可以直接读取, 这些类型自己处理 NULL, 不需要 sql.Null* 类型
+func isScanDirect(column Column) bool {
+	return strings.HasPrefix(column.GoType, "[]") || "hstore.Hstore" == column.GoType
+}
+
+// needNullValue 判断读取列时是否需要先读到 sql.Null* 类型的变量中
+func needNullValue(column Column) bool {
+	return (column.IsNullable || "net.IP" == column.GoType) && !isScanDirect(column) && "" == column.NullStrategy
+}
+
+// toScanValue 返回读取列时传给 Scan 的参数
+func toScanValue(column Column) string {
+	switch {
+	case strings.HasPrefix(column.GoType, "[]") && "[]byte" != column.GoType:
+		return "pq.Array(&value." + column.GoName + ")"
+	case needNullValue(column):
+		return "&null" + CamelCase(column.DbName)
+	default:
+		return "&value." + column.GoName
+	}
+}
+
+// docComment 将数据库中的注释转换为 go 的注释, name 不为空时放在第一行的开头
+func docComment(name, text string) string {
+	lines := strings.Split(strings.TrimSpace(strings.Replace(text, "\r\n", "\n", -1)), "\n")
+	if name != "" {
+		lines[0] = name + " " + lines[0]
+	}
+	for idx := range lines {
+		lines[idx] = strings.TrimRight("// "+lines[idx], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// finder 为一个索引生成的查询方法, 唯一索引生成 FindBy<Cols>, 其它的生成 ListBy<Cols>
+type finder struct {
+	Name     string
+	Index    string
+	IsUnique bool
+	Columns  []Column
+	Params   []string
+}
+
+// toUniqueKeys 返回表中的唯一索引, 用于 Upsert
+func toUniqueKeys(table Table) []Index {
+	var indexes []Index
+	for _, index := range table.Indexes {
+		if index.IsUnique && "" != index.Name {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// toFinders 返回表中索引对应的查询方法, 与主键相同的索引, 有表中不存在的列或有数组,
+// json 等不能直接比较的列的索引将被忽略, 同名的方法只生成一次
+func toFinders(table Table) []finder {
+	if table.IsView {
+		return nil
+	}
+
+	pk := map[string]bool{}
+	for _, column := range table.PrimaryKey {
+		pk[column.DbName] = true
+	}
+
+	var finders []finder
+	names := map[string]bool{}
+next:
+	for _, index := range table.Indexes {
+		f := finder{Index: index.Name, IsUnique: index.IsUnique}
+		isPrimaryKey := len(index.Columns) == len(pk)
+		var goNames []string
+		for _, name := range index.Columns {
+			column := findColumn(table, name)
+			if column == nil || strings.HasPrefix(column.GoType, "[]") {
+				continue next
+			}
+			switch column.DbType {
+			case "json", "jsonb", "hstore":
+				continue next
+			}
+			if !pk[name] {
+				isPrimaryKey = false
+			}
+			f.Columns = append(f.Columns, *column)
+			f.Params = append(f.Params, toParamName(column.GoName))
+			goNames = append(goNames, column.GoName)
+		}
+		if len(f.Columns) == 0 || isPrimaryKey {
+			continue
+		}
+
+		if f.IsUnique {
+			f.Name = "FindBy" + strings.Join(goNames, "And")
+		} else {
+			f.Name = "ListBy" + strings.Join(goNames, "And")
+		}
+		if names[f.Name] {
+			continue
+		}
+		names[f.Name] = true
+		finders = append(finders, f)
+	}
+	return finders
+}
+
+func findColumn(table Table, name string) *Column {
+	for idx := range table.Columns {
+		if table.Columns[idx].DbName == name {
+			return &table.Columns[idx]
+		}
+	}
+	return nil
+}
+
+// toParamName 返回列在方法参数中的名称, 避免与关键字和方法中的变量同名
+func toParamName(goName string) string {
+	name := strings.ToLower(goName[:1]) + goName[1:]
+	switch {
+	case token.IsKeyword(name), name == "db", name == "self", name == "builder":
+		return name + "Value"
+	default:
+		return name
+	}
+}
+
+// toFieldType 返回列在结构中的字段类型
+func toFieldType(column Column) (string, error) {
+	switch column.NullStrategy {
+	case nullPointer:
+		return "*" + column.GoType, nil
+	case nullSQL:
+		return toNullTypeFromPostgres(column)
+	default:
+		return column.GoType, nil
+	}
+}
+
+// isZeroValue 返回判断列的值为空的表达式, 外键为空时不写入它
+func isZeroValue(column Column) string {
+	switch column.NullStrategy {
+	case nullPointer:
+		return "nil == value." + column.GoName
+	case nullSQL:
+		return "!value." + column.GoName + ".Valid"
+	default:
+		if "string" == column.GoType {
+			return `"" == value.` + column.GoName
+		}
+		return "value." + column.GoName + " <= 0"
+	}
+}
+
+// notZeroValue 返回判断列的值不为空的表达式
+func notZeroValue(column Column) string {
+	switch column.NullStrategy {
+	case nullPointer:
+		return "nil != value." + column.GoName
+	case nullSQL:
+		return "value." + column.GoName + ".Valid"
+	default:
+		if "string" == column.GoType {
+			return `"" != value.` + column.GoName
+		}
+		return "value." + column.GoName + " > 0"
+	}
+}
+
+// setNowValue 返回将 created_at 或 updated_at 设为当前时间的语句
+func setNowValue(table Table, name string) (string, error) {
+	for _, column := range table.Columns {
+		if column.DbName != name {
+			continue
+		}
+		switch column.NullStrategy {
+		case nullPointer:
+			return "value." + column.GoName + " = new(time.Time)\n  *value." + column.GoName + " = time.Now()", nil
+		case nullSQL:
+			return "value." + column.GoName + " = pq.NullTime{Time: time.Now(), Valid: true}", nil
+		default:
+			return "value." + column.GoName + " = time.Now()", nil
+		}
+	}
+	return "", errors.New("column '" + name + "' isn't found in '" + table.TableName + "'")
+}
+
+// toSQLValue 返回写入列时的值
+func toSQLValue(column Column) string {
+	switch {
+	case strings.HasPrefix(column.GoType, "[]") && "[]byte" != column.GoType:
+		return "pq.Array(value." + column.GoName + ")"
+	case "net.IP" == column.GoType || "JSON" == column.GoType:
+		return "value." + column.GoName + ".String()"
+	default:
+		return "value." + column.GoName
+	}
+}
+
+// toBatchValue 返回批量插入时列的值的表达式, 与 CreateIt 一样, 为零的外键写为 NULL
+func toBatchValue(column Column) string {
+	if column.IsForeignKey && (column.NullStrategy == "" || column.NullStrategy == nullZero) {
+		return "nullIfZero(" + toSQLValue(column) + ")"
+	}
+	return toSQLValue(column)
+}
+
+// toNullTypeFromPostgres 返回读取列时用的 sql.Null* 类型
+func toNullTypeFromPostgres(column Column) (string, error) {
+	switch column.GoType {
 	case "bool":
-		return "sql.NullBool"
-	case "int4":
-		return "sql.NullInt64"
-	case "int8":
-		return "sql.NullInt64"
-	case "float4":
-		return "sql.NullFloat64"
-	case "float8", "numeric":
-		return "sql.NullFloat64"
-	case "varchar", "text":
-		return "sql.NullString"
-	case "timestamp", "timestamptz":
-		return "pq.NullTime"
-	case "cidr":
-		return "sql.NullString"
-	case "macaddr":
-		return "sql.NullString"
-	case "json", "jsonb":
-		return "[]byte" // "sql.RawBytes" -- sql: RawBytes isn't allowed on Row.Scan
+		return "sql.NullBool", nil
+	case "int", "int16", "int64":
+		return "sql.NullInt64", nil
+	case "float32", "float64":
+		return "sql.NullFloat64", nil
+	case "string", "net.IP":
+		return "sql.NullString", nil
+	case "time.Time":
+		return "pq.NullTime", nil
+	case "JSON":
+		return "[]byte", nil // "sql.RawBytes" -- sql: RawBytes isn't allowed on Row.Scan
+	default:
+		return "", errors.New("'" + column.DbType + "' of '" + column.DbName + "' is unsupported")
+	}
+}
+
+// toNullValue 返回从 sql.Null* 类型的变量中取值并转换为列的 go 类型的表达式
+func toNullValue(column Column) (string, error) {
+	name := "null" + CamelCase(column.DbName)
+	switch column.GoType {
+	case "bool":
+		return name + ".Bool", nil
+	case "int", "int16":
+		return column.GoType + "(" + name + ".Int64)", nil
+	case "int64":
+		return name + ".Int64", nil
+	case "float32":
+		return "float32(" + name + ".Float64)", nil
+	case "float64":
+		return name + ".Float64", nil
+	case "string":
+		return name + ".String", nil
+	case "time.Time":
+		return name + ".Time", nil
 	default:
-		panic("'" + nm + "' is unsupported")
+		return "", errors.New("'" + column.DbType + "' of '" + column.DbName + "' is unsupported")
 	}
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/three-plus-three/gengen/generator"
+)
+
+const testNullableDDL = `
+CREATE TABLE tpt_authors (
+  id   serial PRIMARY KEY,
+  name varchar(100) NOT NULL
+);
+CREATE TABLE tpt_books (
+  id         serial PRIMARY KEY,
+  name       varchar(100) NOT NULL,
+  isbn       varchar(20),
+  price      float8,
+  pages      integer,
+  author_id  integer REFERENCES tpt_authors(id),
+  created_at timestamp,
+  updated_at timestamp
+);
+CREATE TABLE tpt_things (
+  id   uuid PRIMARY KEY,
+  name varchar(100) NOT NULL
+);
+`
+
+// generateTestModels 用 models 命令的模板从 testNullableDDL 生成代码, 写到 sink 的 pkg 目录中
+func generateTestModels(t *testing.T, sink *generator.MemorySink, pkg, nullable, nullableColumns string) string {
+	dir, err := ioutil.TempDir("", "gengen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ddl := filepath.Join(dir, "schema.sql")
+	if err := ioutil.WriteFile(ddl, []byte(testNullableDDL), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &GenerateModelsCommand{dbBase: dbBase{ddl: ddl, dbPrefix: "tpt_"},
+		ns:              "models",
+		nullable:        nullable,
+		nullableColumns: nullableColumns}
+	if err := cmd.init(); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := cmd.GetAllTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.initNullable(tables); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := sink.Create(pkg+"/models.go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.templateHeader.Execute(out, map[string]interface{}{"Namespace": cmd.ns}); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		if err := cmd.genrateFromTable(out, table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Close()
+
+	base, err := sink.Create(pkg+"/base.go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.Write([]byte(strings.Replace(string(generator.DefaultTemplate("base")), "package main", "package models", 1)))
+	base.Close()
+
+	bs, _ := sink.Get(pkg + "/models.go")
+	return string(bs)
+}
+
+func TestModelsNullable(t *testing.T) {
+	sink := generator.NewMemorySink()
+	for idx, test := range []struct {
+		nullable        string
+		nullableColumns string
+		excepted        []string
+	}{
+		{nullable: "zero", excepted: []string{
+			"Price float64\t", "Pages int\t", "AuthorId int64\t", "CreatedAt time.Time\t",
+			"var nullPrice sql.NullFloat64", "value.Price = nullPrice.Float64",
+			"value.CreatedAt = time.Now()", "if value.AuthorId > 0 {", `Set("price", value.Price)`}},
+		{nullable: "pointer", excepted: []string{
+			"Price *float64\t", "Pages *int\t", "AuthorId *int64\t", "CreatedAt *time.Time\t",
+			"&value.Price,", "*value.CreatedAt = time.Now()", "if nil != value.AuthorId {", `Set("price", value.Price)`}},
+		{nullable: "sql", excepted: []string{
+			"Price sql.NullFloat64\t", "Pages sql.NullInt64\t", "AuthorId sql.NullInt64\t", "CreatedAt pq.NullTime\t",
+			"&value.Price,", "value.CreatedAt = pq.NullTime{Time: time.Now(), Valid: true}",
+			"if value.AuthorId.Valid {", `Set("price", value.Price)`}},
+		{nullable: "zero", nullableColumns: "tpt_books.price=pointer, tpt_books.isbn=sql", excepted: []string{
+			"Price *float64\t", "Isbn sql.NullString\t", "Pages int\t", "AuthorId int64\t", "&value.Price,"}},
+	} {
+		text := generateTestModels(t, sink, "models"+strconv.Itoa(idx), test.nullable, test.nullableColumns)
+		for _, s := range test.excepted {
+			if !strings.Contains(text, s) {
+				t.Errorf("%s %s: '%s' isn't found", test.nullable, test.nullableColumns, s)
+			}
+		}
+		// 主键和 NOT NULL 的列不受影响
+		if !strings.Contains(text, "\nName string\t") || !strings.Contains(text, "\n  Id int64\t") {
+			t.Errorf("%s %s: not null columns is changed", test.nullable, test.nullableColumns)
+		}
+		// 非整数的 id 使用列的类型
+		if !strings.Contains(text, "\n  Id string\t") {
+			t.Errorf("%s %s: uuid id isn't string", test.nullable, test.nullableColumns)
+		}
+	}
+
+	// 检查生成的代码能否通过编译, 模板的错误会在这里发现
+	errs, err := generator.Verify(sink, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range errs {
+		t.Error(e)
+	}
+
+	for _, test := range []struct{ nullable, nullableColumns string }{
+		{"bad", ""},
+		{"zero", "tpt_books.price=bad"},
+		{"zero", "tpt_books.price"},
+	} {
+		cmd := &GenerateModelsCommand{nullable: test.nullable, nullableColumns: test.nullableColumns}
+		if err := cmd.initNullable(nil); err == nil {
+			t.Errorf("%s %s: excepted error", test.nullable, test.nullableColumns)
+		}
+	}
+}
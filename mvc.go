@@ -5,6 +5,8 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"path/filepath"
+
+	"github.com/three-plus-three/gengen/generator"
 )
 
 // GenerateMVCCommand - 生成代码
@@ -27,61 +29,130 @@ func (cmd *GenerateMVCCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	return cmd.baseCommand.Flags(fs)
 }
 
+// mvcDirs - mvc 中各个生成器的默认输出目录, 它们相对于 output 目录. 可以在 gengen.yaml
+// 中用 generators.<name>.output 修改, 它和其它路径一样是相对于配置文件所在的目录的,
+// 目录下文件的路径则由 paths 决定
+var mvcDirs = map[string]string{
+	"struct":     filepath.Join("app", "models"),
+	"views":      filepath.Join("app", "views"),
+	"js":         filepath.Join("public", "js"),
+	"controller": filepath.Join("app", "controllers"),
+	"test":       "",
+}
+
 // Run - 生成数据库模型代码
 func (cmd *GenerateMVCCommand) Run(args []string) error {
 	go http.ListenAndServe(":", nil)
 
+	if err := cmd.loadConfig("mvc"); err != nil {
+		return err
+	}
+
+	// 所有子生成器共用一个暂存区, 全部成功后才写到 output 目录中
+	cmd.staging = generator.NewStagingSink(generator.DirSink{})
+
 	var st GenerateStructCommand
 	var views GenerateViewCommand
 	var js GenerateJSCommand
 	var ctl GenerateControllerCommand
 	var ut GenerateUnitTestCommand
 
+	stFlags := st.Flags(flag.NewFlagSet("struct", flag.ContinueOnError))
 	st.ns = "models"
 	st.theme = cmd.theme
 	st.CopyFrom(&cmd.baseCommand)
-	st.output = filepath.Join(cmd.output, "app", "models")
+	st.output = filepath.Join(cmd.output, mvcDirs["struct"])
+	if err := cmd.configure(&st.baseCommand, "struct", stFlags); err != nil {
+		return err
+	}
+
+	viewsFlags := views.Flags(flag.NewFlagSet("views", flag.ContinueOnError))
 	views.CopyFrom(&cmd.baseCommand)
 	views.ns = "views"
 	views.theme = cmd.theme
 	views.layouts = cmd.layouts
 	views.customPath = cmd.customPath
 	views.viewTag = cmd.viewTag
-	views.output = filepath.Join(cmd.output, "app", "views")
+	views.output = filepath.Join(cmd.output, mvcDirs["views"])
+	if err := cmd.configure(&views.baseCommand, "views", viewsFlags); err != nil {
+		return err
+	}
+
+	jsFlags := js.Flags(flag.NewFlagSet("js", flag.ContinueOnError))
 	js.CopyFrom(&cmd.baseCommand)
 	js.ns = "js"
 	js.theme = cmd.theme
-	js.output = filepath.Join(cmd.output, "public", "js")
+	js.output = filepath.Join(cmd.output, mvcDirs["js"])
+	if err := cmd.configure(&js.baseCommand, "js", jsFlags); err != nil {
+		return err
+	}
+
+	ctlFlags := ctl.Flags(flag.NewFlagSet("controller", flag.ContinueOnError))
 	ctl.CopyFrom(&cmd.baseCommand)
 	ctl.ns = "controllers"
 	ctl.theme = cmd.theme
 	ctl.controller = cmd.controller
 	ctl.projectPath = cmd.projectPath
-	ctl.output = filepath.Join(cmd.output, "app", "controllers")
+	ctl.output = filepath.Join(cmd.output, mvcDirs["controller"])
+	if err := cmd.configure(&ctl.baseCommand, "controller", ctlFlags); err != nil {
+		return err
+	}
+
+	utFlags := ut.Flags(flag.NewFlagSet("test", flag.ContinueOnError))
 	ut.CopyFrom(&cmd.baseCommand)
 	ut.ns = "tests"
 	ut.theme = cmd.theme
 	ut.projectPath = cmd.projectPath
-	ut.output = cmd.output
+	ut.output = filepath.Join(cmd.output, mvcDirs["test"])
+	if err := cmd.configure(&ut.baseCommand, "test", utFlags); err != nil {
+		return err
+	}
 
-	if err := st.Run(args); err != nil {
+	if err := st.generate(args); err != nil {
 		return err
 	}
 
-	if err := views.Run(args); err != nil {
+	if err := views.generate(args); err != nil {
 		return err
 	}
 
-	if err := js.Run(args); err != nil {
+	if err := js.generate(args); err != nil {
 		return err
 	}
 
-	if err := ctl.Run(args); err != nil {
+	if err := ctl.generate(args); err != nil {
 		return err
 	}
 
-	if err := ut.Run(args); err != nil {
+	if err := ut.generate(args); err != nil {
 		return err
 	}
-	return nil
+	return cmd.commit(cmd.staging)
+}
+
+// configure 将项目配置文件中子生成器的配置应用到子命令上, 在 mvc 命令行中显式指定的参数优先
+func (cmd *GenerateMVCCommand) configure(sub *baseCommand, name string, fs *flag.FlagSet) error {
+	if cmd.config == nil {
+		return nil
+	}
+	gen, ok := cmd.config.Generators[name]
+	if !ok {
+		return nil
+	}
+
+	sub.variables = map[string]interface{}{}
+	for k, v := range cmd.variables {
+		sub.variables[k] = v
+	}
+	for k, v := range gen.Variables {
+		sub.variables[k] = v
+	}
+	sub.paths = map[string]string{}
+	for k, v := range cmd.paths {
+		sub.paths[k] = v
+	}
+	for k, v := range gen.Paths {
+		sub.paths[k] = v
+	}
+	return cmd.config.apply(fs, name, gen.Values, nil, cmd.isFlagSet)
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// schemaSnapshot - 从数据库中读出的表结构的快照, 可以提交到代码库中, 之后用 -snapshot
+// 参数代替 -db_url 来生成代码, 这样生成时不需要连接数据库, 并且结果是确定的
+type schemaSnapshot struct {
+	Driver string          `json:"driver,omitempty" yaml:"driver,omitempty"`
+	Schema string          `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Tables []snapshotTable `json:"tables" yaml:"tables"`
+}
+
+type snapshotTable struct {
+	Name    string           `json:"name" yaml:"name"`
+	Schema  string           `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Comment string           `json:"comment,omitempty" yaml:"comment,omitempty"`
+	IsView  bool             `json:"view,omitempty" yaml:"view,omitempty"`
+	Columns []snapshotColumn `json:"columns" yaml:"columns"`
+	Indexes []snapshotIndex  `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+}
+
+type snapshotColumn struct {
+	Name         string   `json:"name" yaml:"name"`
+	Type         string   `json:"type" yaml:"type"`
+	Comment      string   `json:"comment,omitempty" yaml:"comment,omitempty"`
+	IsNullable   bool     `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	IsPrimaryKey bool     `json:"primary_key,omitempty" yaml:"primary_key,omitempty"`
+	IsForeignKey bool     `json:"foreign_key,omitempty" yaml:"foreign_key,omitempty"`
+	IsSequence   bool     `json:"sequence,omitempty" yaml:"sequence,omitempty"`
+	RefTable     string   `json:"ref_table,omitempty" yaml:"ref_table,omitempty"`
+	RefColumn    string   `json:"ref_column,omitempty" yaml:"ref_column,omitempty"`
+	Precision    int      `json:"precision,omitempty" yaml:"precision,omitempty"`
+	Scale        int      `json:"scale,omitempty" yaml:"scale,omitempty"`
+	EnumValues   []string `json:"enum,omitempty" yaml:"enum,omitempty"`
+	MaxLength    int      `json:"max_length,omitempty" yaml:"max_length,omitempty"`
+	DefaultValue string   `json:"default,omitempty" yaml:"default,omitempty"`
+	CheckValues  []string `json:"check_values,omitempty" yaml:"check_values,omitempty"`
+	MinValue     string   `json:"min_value,omitempty" yaml:"min_value,omitempty"`
+	MaxValue     string   `json:"max_value,omitempty" yaml:"max_value,omitempty"`
+}
+
+type snapshotIndex struct {
+	Name     string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Columns  []string `json:"columns" yaml:"columns"`
+	IsUnique bool     `json:"unique,omitempty" yaml:"unique,omitempty"`
+}
+
+// toSnapshot 将读出的表转换为快照, 只记录从数据库中读出的内容, 不记录 ClassName,
+// GoType 等由参数决定的内容
+func toSnapshot(driver, schema string, tables []Table) *schemaSnapshot {
+	snapshot := &schemaSnapshot{Driver: driver, Schema: schema, Tables: make([]snapshotTable, 0, len(tables))}
+	for _, table := range tables {
+		st := snapshotTable{Name: table.TableName,
+			Schema:  table.Schema,
+			Comment: table.Comment,
+			IsView:  table.IsView}
+		for _, column := range table.Columns {
+			st.Columns = append(st.Columns, snapshotColumn{Name: column.DbName,
+				Type:         column.DbType,
+				Comment:      column.Comment,
+				IsNullable:   column.IsNullable,
+				IsPrimaryKey: column.IsPrimaryKey,
+				IsForeignKey: column.IsForeignKey,
+				IsSequence:   column.IsSequence,
+				RefTable:     column.RefTable,
+				RefColumn:    column.RefColumn,
+				Precision:    column.Precision,
+				Scale:        column.Scale,
+				EnumValues:   column.EnumValues,
+				MaxLength:    column.MaxLength,
+				DefaultValue: column.DefaultValue,
+				CheckValues:  column.CheckValues,
+				MinValue:     column.MinValue,
+				MaxValue:     column.MaxValue})
+		}
+		for _, index := range table.Indexes {
+			st.Indexes = append(st.Indexes, snapshotIndex{Name: index.Name, Columns: index.Columns, IsUnique: index.IsUnique})
+		}
+		snapshot.Tables = append(snapshot.Tables, st)
+	}
+	return snapshot
+}
+
+// toTables 将快照转换为表, 与 dialect 返回的一样, 表的 GoName, GoType 等还需要调用 initTable 来设置
+func (snapshot *schemaSnapshot) toTables() []Table {
+	tables := make([]Table, 0, len(snapshot.Tables))
+	for _, st := range snapshot.Tables {
+		table := Table{Schema: st.Schema,
+			TableName: st.Name,
+			Comment:   st.Comment,
+			IsView:    st.IsView}
+		for _, sc := range st.Columns {
+			table.Columns = append(table.Columns, Column{DbName: sc.Name,
+				DbType:       sc.Type,
+				Comment:      sc.Comment,
+				IsNullable:   sc.IsNullable,
+				IsPrimaryKey: sc.IsPrimaryKey,
+				IsForeignKey: sc.IsForeignKey,
+				IsSequence:   sc.IsSequence,
+				RefTable:     sc.RefTable,
+				RefColumn:    sc.RefColumn,
+				Precision:    sc.Precision,
+				Scale:        sc.Scale,
+				EnumValues:   sc.EnumValues,
+				MaxLength:    sc.MaxLength,
+				DefaultValue: sc.DefaultValue,
+				CheckValues:  sc.CheckValues,
+				MinValue:     sc.MinValue,
+				MaxValue:     sc.MaxValue})
+		}
+		for _, index := range st.Indexes {
+			table.Indexes = append(table.Indexes, Index{Name: index.Name, Columns: index.Columns, IsUnique: index.IsUnique})
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// isYAMLFile 按扩展名判断快照的格式, .yaml 和 .yml 为 YAML, 其它的为 JSON
+func isYAMLFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// loadSnapshot 从快照文件中读取表结构
+func loadSnapshot(filename string) ([]Table, error) {
+	bs, e := ioutil.ReadFile(filename)
+	if nil != e {
+		return nil, e
+	}
+
+	var snapshot schemaSnapshot
+	if isYAMLFile(filename) {
+		e = yaml.Unmarshal(bs, &snapshot)
+	} else {
+		e = json.Unmarshal(bs, &snapshot)
+	}
+	if nil != e {
+		return nil, errors.New("read snapshot '" + filename + "' fail, " + e.Error())
+	}
+	return snapshot.toTables(), nil
+}
+
+// saveSnapshot 将快照写到文件中
+func saveSnapshot(filename string, snapshot *schemaSnapshot) error {
+	var bs []byte
+	var e error
+	if isYAMLFile(filename) {
+		bs, e = yaml.Marshal(snapshot)
+	} else {
+		bs, e = json.MarshalIndent(snapshot, "", "  ")
+		bs = append(bs, '\n')
+	}
+	if nil != e {
+		return errors.New("marshal snapshot fail, " + e.Error())
+	}
+	return ioutil.WriteFile(filename, bs, 0644)
+}
+
+// GenerateSnapshotCommand - 将数据库的表结构保存为 JSON 或 YAML 格式的快照
+type GenerateSnapshotCommand struct {
+	dbBase
+	output string
+	flags  *flag.FlagSet
+}
+
+// Flags - 申明参数
+func (cmd *GenerateSnapshotCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.flags = fs
+	cmd.initFlags(fs)
+	fs.StringVar(&cmd.output, "output", "schema.json", "the snapshot file, it is YAML if the extension is .yaml or .yml, otherwise JSON")
+	return fs
+}
+
+// Run - 保存快照
+func (cmd *GenerateSnapshotCommand) Run(args []string) error {
+	if _, e := loadConfig(cmd.flags, "snapshot"); nil != e {
+		return e
+	}
+
+	tables, e := cmd.readTables()
+	if nil != e {
+		return e
+	}
+	tables, e = cmd.filterTables(tables)
+	if nil != e {
+		return e
+	}
+
+	log.Println("GEN ", cmd.output)
+	return saveSnapshot(cmd.output, toSnapshot(cmd.dbDrv, cmd.dbSchema, tables))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	p := &ddlParser{}
+	if err := p.parse(testDDL); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := p.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"schema.json", "schema.yaml"} {
+		filename := filepath.Join(dir, name)
+		if err := saveSnapshot(filename, toSnapshot("postgres", "public", tables)); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := dbBase{dbPrefix: "tpt_", snapshot: filename}
+		replayed, err := cmd.GetAllTables()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cmd = dbBase{dbPrefix: "tpt_"}
+		excepted := append([]Table(nil), tables...)
+		for idx := range excepted {
+			excepted[idx].Columns = append([]Column(nil), excepted[idx].Columns...)
+			if err := cmd.initTable(&excepted[idx], excepted[idx].Columns); err != nil {
+				t.Fatal(err)
+			}
+		}
+		initRelations(excepted)
+		if !reflect.DeepEqual(excepted, replayed) {
+			t.Errorf("%s: excepted %#v\r\nactual %#v", name, excepted, replayed)
+		}
+	}
+}
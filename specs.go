@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/three-plus-three/gengen/types"
+	"gopkg.in/yaml.v2"
+)
+
+// GenerateSpecsCommand - 从数据库的表结构生成类的规格说明, 每个表一个 yaml 文件
+type GenerateSpecsCommand struct {
+	dbBase
+	output   string
+	override bool
+	flags    *flag.FlagSet
+}
+
+// Flags - 申明参数
+func (cmd *GenerateSpecsCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.flags = fs
+	cmd.initFlags(fs)
+	fs.StringVar(&cmd.output, "output", "specs", "the output directory")
+	fs.BoolVar(&cmd.override, "override", false, "")
+	return fs
+}
+
+// Run - 生成规格说明
+func (cmd *GenerateSpecsCommand) Run(args []string) error {
+	if _, e := loadConfig(cmd.flags, "specs"); nil != e {
+		return e
+	}
+
+	tables, e := cmd.GetAllTables()
+	if nil != e {
+		return e
+	}
+
+	if e := os.MkdirAll(cmd.output, 0755); nil != e {
+		return e
+	}
+	for _, table := range tables {
+		if table.IsView {
+			continue
+		}
+
+		filename := filepath.Join(cmd.output, Underscore(table.ClassName)+".yaml")
+		if !cmd.override {
+			if _, e := os.Stat(filename); nil == e {
+				log.Println("[WARN] [EXISTS] skip", filename)
+				continue
+			}
+		}
+
+		bs, e := yaml.Marshal(toClassSpec(table))
+		if nil != e {
+			return errors.New("marshal '" + table.TableName + "' fail, " + e.Error())
+		}
+		log.Println("GEN ", filename)
+		if e := ioutil.WriteFile(filename, bs, 0644); nil != e {
+			return e
+		}
+	}
+	return nil
+}
+
+// toClassSpec 将表转换为类的规格说明, 表和列的注释的第一行作为 Label, 其余的作为 Description
+func toClassSpec(table Table) *types.ClassSpec {
+	cs := &types.ClassSpec{Name: table.ClassName, Table: table.TableName}
+	cs.Label, _ = splitComment(table.Comment)
+	if table.IsCombinedKey {
+		for _, column := range table.PrimaryKey {
+			cs.PrimaryKey = append(cs.PrimaryKey, column.DbName)
+		}
+	}
+
+	uniques := map[string]bool{}
+	for _, index := range table.Indexes {
+		if !index.IsUnique {
+			continue
+		}
+		if len(index.Columns) == 1 {
+			uniques[index.Columns[0]] = true
+		} else {
+			cs.Keys = append(cs.Keys, index.Columns)
+		}
+	}
+
+	for _, column := range table.Columns {
+		field := types.FieldSpec{Name: column.DbName,
+			Type:         toSpecType(column),
+			Collection:   strings.HasPrefix(column.DbType, "_"),
+			IsRequired:   !column.IsNullable && !column.IsSequence && !column.IsPrimaryKey,
+			IsUniquely:   uniques[column.DbName],
+			DefaultValue: column.DefaultValue,
+			Restrictions: toRestrictions(column)}
+		field.Label, field.Description = splitComment(column.Comment)
+		cs.Fields = append(cs.Fields, field)
+	}
+
+	for _, relation := range table.BelongsTo {
+		belongsTo := types.BelongsTo{Target: relation.TargetClass}
+		if relation.Column.DbName != Underscore(relation.TargetClass)+"_id" {
+			belongsTo.Name = relation.Column.DbName
+		}
+		cs.BelongsTo = append(cs.BelongsTo, belongsTo)
+	}
+	return cs
+}
+
+// toRestrictions 返回列的长度, 枚举值和取值范围的限制, 没有限制时返回 nil
+func toRestrictions(column Column) *types.RestrictionSpec {
+	values := column.CheckValues
+	if len(values) == 0 && "enum" == column.DbType {
+		values = column.EnumValues
+	}
+	if column.MaxLength <= 0 && len(values) == 0 && column.MinValue == "" && column.MaxValue == "" {
+		return nil
+	}
+
+	restrictions := &types.RestrictionSpec{MaxLength: column.MaxLength,
+		MinValue: column.MinValue,
+		MaxValue: column.MaxValue}
+	for _, value := range values {
+		restrictions.Enumerations = append(restrictions.Enumerations, types.EnumerationValue{Label: value, Value: value})
+	}
+	return restrictions
+}
+
+// splitComment 将注释分为第一行和其余的行
+func splitComment(comment string) (string, string) {
+	comment = strings.TrimSpace(strings.Replace(comment, "\r\n", "\n", -1))
+	idx := strings.Index(comment, "\n")
+	if idx < 0 {
+		return comment, ""
+	}
+	return strings.TrimSpace(comment[:idx]), strings.TrimSpace(comment[idx+1:])
+}
+
+// toSpecType 返回列在规格说明中的类型
+func toSpecType(column Column) string {
+	if column.IsPrimaryKey && !column.IsForeignKey && "id" == column.DbName {
+		return "objectId"
+	}
+	switch strings.TrimPrefix(column.DbType, "_") {
+	case "bool":
+		return "boolean"
+	case "int2", "int4":
+		return "integer"
+	case "int8":
+		return "biginteger"
+	case "float4", "float8":
+		return "decimal"
+	case "numeric":
+		if "int64" == column.GoType {
+			return "biginteger"
+		}
+		return "decimal"
+	case "timestamp", "timestamptz", "time", "timetz":
+		return "datetime"
+	case "date":
+		return "date"
+	case "cidr", "inet":
+		return "ipAddress"
+	case "macaddr":
+		return "physicalAddress"
+	case "json", "jsonb", "hstore":
+		return "map"
+	case "bytea":
+		return "dynamic"
+	default:
+		return "string"
+	}
+}
@@ -1,12 +1,5 @@
 package main
 
-import (
-	"path/filepath"
-	"text/template"
-
-	"github.com/three-plus-three/gengen/types"
-)
-
 // GenerateStructCommand - 生成数据库模型代码
 type GenerateStructCommand struct {
 	baseCommand
@@ -14,17 +7,12 @@ type GenerateStructCommand struct {
 
 // Run - 生成数据库模型代码
 func (cmd *GenerateStructCommand) Run(args []string) error {
-	return cmd.run(args, cmd.generateStruct)
+	if err := cmd.loadConfig("struct"); err != nil {
+		return err
+	}
+	return cmd.generate(args)
 }
 
-func (cmd *GenerateStructCommand) generateStruct(cls *types.ClassSpec) error {
-	funcs := template.FuncMap{
-		"omitempty": func(t types.FieldSpec) bool {
-			return !t.IsRequired
-		}}
-	params := map[string]interface{}{"namespace": cmd.ns,
-		"class": cls}
-
-	return cmd.executeTempate(cmd.override, []string{"ns", "struct"}, funcs, params,
-		filepath.Join(cmd.output, Underscore(Pluralize(cls.Name))+".go"))
+func (cmd *GenerateStructCommand) generate(args []string) error {
+	return cmd.run(args, "struct", cmd.options())
 }
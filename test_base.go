@@ -1,12 +1,6 @@
 package main
 
-import (
-	"flag"
-	"path/filepath"
-	"text/template"
-
-	"github.com/three-plus-three/gengen/types"
-)
+import "flag"
 
 // GenerateModelsCommand - 生成数据库模型代码
 type GenerateTestCommand struct {
@@ -22,20 +16,11 @@ func (cmd *GenerateTestCommand) Flags(fs *flag.FlagSet) *flag.FlagSet {
 
 // Run - 生成数据库模型代码
 func (cmd *GenerateTestCommand) Run(args []string) error {
-	return cmd.runAll(args, cmd.generateStructs)
-}
-
-func (cmd *GenerateTestCommand) generateStructs(tables []*types.ClassSpec) error {
-	funcs := template.FuncMap{
-		"omitempty": func(t types.FieldSpec) bool {
-			return !t.IsRequired
-		},
-		"tableName": getTableName}
-
-	params := map[string]interface{}{"namespace": cmd.ns,
-		"classes":     tables,
-		"projectPath": cmd.projectPath}
+	if err := cmd.loadConfig("test_base"); err != nil {
+		return err
+	}
 
-	return cmd.executeTempate(cmd.override, []string{"tests/test_base"}, funcs, params,
-		filepath.Join(cmd.output, "tests", "test_base.go"))
+	opts := cmd.options()
+	opts.ProjectPath = cmd.projectPath
+	return cmd.runAll(args, "test_base", opts)
 }